@@ -0,0 +1,109 @@
+// This file contains search for formulas approximating a real number, like pi.
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Approximation is a formula with the value Val close to the target of FindApproximations.
+// Dist is the distance from the middle of Val to the target, and Err is an upper bound
+// of the distance from the real value of the formula to the target.
+type Approximation struct {
+	Formula   *Node
+	Val       interval
+	Dist, Err float64
+}
+
+// String prints the value of a only with the significant digits which its bounds support.
+func (a Approximation) String() string {
+	return fmt.Sprintf("%s = %s, error <= %.3g", a.Formula, significant(a.Val), a.Err)
+}
+
+// maxSignificant is the number of significant digits printed for exact values.
+const maxSignificant = 15
+
+// significant returns the middle of i with as many significant digits as the width of i
+// allows, from 1 to maxSignificant.
+func significant(i interval) string {
+	mid, digits := i.Mid(), maxSignificant
+	if w := i.hi - i.lo; !i.exact && w > 0 && mid != 0 {
+		digits = int(math.Floor(math.Log10(math.Abs(mid) / w)))
+		digits = int(math.Max(1, math.Min(maxSignificant, float64(digits))))
+	}
+	return strconv.FormatFloat(mid, 'g', digits, 64)
+}
+
+// targets are constants which can be used as targets by name.
+var targets = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"phi": math.Phi,
+}
+
+// parseTarget returns the number named s in targets, or written as s.
+func parseTarget(s string) (float64, error) {
+	if t, ok := targets[s]; ok {
+		return t, nil
+	}
+	t, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("target should be pi, e, phi or a number, not '%s'", s)
+	}
+	return t, nil
+}
+
+// approxUnaryChain is maxUnaryChain for FindApproximations: almost every inexact value
+// has a square root, so longer chains make too many values.
+const approxUnaryChain = 1
+
+// approxDigits is the number of significant digits of values considered the same by
+// FindApproximations.
+const approxDigits = 12
+
+// FindApproximations searches for values made of digits using float intervals, and
+// returns up to top of them closest to target with their shortest formulas.
+func FindApproximations(digits string, target float64, top int) []Approximation {
+	defer func(b bool, n int) { intervals, maxUnaryChain = b, n }(intervals, maxUnaryChain)
+	intervals, maxUnaryChain = true, approxUnaryChain
+	p, _ := Search(digits)
+	// The same value calculated in different ways may get slightly different bounds,
+	// so values are grouped by the first approxDigits digits.
+	best := make(map[string]Approximation)
+	for _, s := range p {
+		i, n := s.val.(interval), s.shortest()
+		if n == nil {
+			continue
+		}
+		a := Approximation{
+			Formula: n,
+			Val:     i,
+			Dist:    math.Abs(i.Mid() - target),
+			Err:     math.Max(math.Abs(i.lo-target), math.Abs(i.hi-target)),
+		}
+		key := strconv.FormatFloat(i.Mid(), 'g', approxDigits, 64)
+		if b, ok := best[key]; !ok || n.Depth() < b.Formula.Depth() ||
+			n.Depth() == b.Formula.Depth() && n.String() < b.Formula.String() {
+			best[key] = a
+		}
+	}
+	var result []Approximation
+	for _, a := range best {
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ai, aj := result[i], result[j]
+		if ai.Dist != aj.Dist {
+			return ai.Dist < aj.Dist
+		} else if di, dj := ai.Formula.Depth(), aj.Formula.Depth(); di != dj {
+			return di < dj
+		}
+		return ai.Formula.String() < aj.Formula.String()
+	})
+	if len(result) > top {
+		result = result[:top]
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindApproximations(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 0
+	result := FindApproximations("123", math.Pi, 5)
+	if assert.Len(result, 5) {
+		a := result[0]
+		assert.True(a.Err < 1e-3)
+		assert.True(a.Val.lo <= math.Pi+a.Err && math.Pi-a.Err <= a.Val.hi)
+		v, err := a.Formula.Eval()
+		assert.NoError(err)
+		assert.InDelta(math.Pi, v.(interval).Mid(), 1e-3)
+	}
+	for i := 1; i < len(result); i++ {
+		assert.True(result[i-1].Dist <= result[i].Dist)
+	}
+	assert.False(intervals)
+
+	n, err := FromPolish("sqrt 2")
+	assert.NoError(err)
+	v, err := exactInterval(rational{2, 1}).PerformUnary(OpSqrt)
+	assert.NoError(err)
+	// Bounds are kept to intervalBits bits, about 12 significant digits
+	a := Approximation{Formula: n, Val: v.(interval), Err: 1e-3}
+	assert.Equal("sqrt(2) = 1.4142135624, error <= 0.001", a.String())
+	assert.Equal("0.5", significant(exactInterval(rational{1, 2})))
+	assert.Equal("1e+01", significant(interval{lo: 9, hi: 11}))
+
+	_, err = parseTarget("phi")
+	assert.NoError(err)
+	_, err = parseTarget("tau")
+	assert.Error(err)
+}
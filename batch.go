@@ -0,0 +1,208 @@
+// This file contains the batch mode, solving many queries read from a file.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// batchQuery is a single query of the batch mode. It's written on a line as
+// "digits target" or "digits min max", optionally followed by options: depth=N.
+// Digits may be separated by commas, like 25,50,7, and digits and numbers are
+// written in the base numBase, like on the command line.
+type batchQuery struct {
+	Digits   string
+	Min, Max int64
+	Depth    int64
+}
+
+// batchFormula is a formula in batchResult.
+type batchFormula struct {
+	Formula string `json:"formula"`
+	Depth   int64  `json:"depth"`
+}
+
+// batchResult lists formulas found for a value.
+type batchResult struct {
+	Value    string         `json:"value"`
+	Formulas []batchFormula `json:"formulas"`
+}
+
+// batchRecord is written for every query of the batch mode as a single line of JSON.
+// Results is empty if there are no solutions, and Error is set if the query is invalid.
+type batchRecord struct {
+	Line    int           `json:"line"`
+	Query   string        `json:"query"`
+	Digits  string        `json:"digits,omitempty"`
+	Min     int64         `json:"min"`
+	Max     int64         `json:"max"`
+	Depth   int64         `json:"depth"`
+	Results []batchResult `json:"results"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// parseBatchQuery parses a query line.
+func parseBatchQuery(line string) (batchQuery, error) {
+	var q batchQuery
+	var nums []int64
+	for i, f := range strings.Fields(line) {
+		if i == 0 {
+			q.Digits = strings.ToLower(f)
+		} else if k, v, ok := strings.Cut(f, "="); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return q, fmt.Errorf("invalid value of %s: %s", k, v)
+			}
+			switch k {
+			case "depth":
+				q.Depth = n
+			default:
+				return q, fmt.Errorf("unknown option %s", k)
+			}
+		} else if n, err := parseInt(f); err == nil {
+			nums = append(nums, n)
+		} else {
+			return q, fmt.Errorf("cannot convert %s to number", f)
+		}
+	}
+	tokens, separated := splitTokens(q.Digits)
+	if !isDigits(strings.Join(tokens, "")) {
+		return q, fmt.Errorf("invalid digits '%s'", q.Digits)
+	} else if err := checkNumbers(tokens, separated); err != nil {
+		return q, err
+	} else if separated {
+		q.Digits = strings.Join(tokens, ",")
+	}
+	switch len(nums) {
+	case 1:
+		q.Min, q.Max = nums[0], nums[0]
+	case 2:
+		q.Min, q.Max = nums[0], nums[1]
+	default:
+		return q, fmt.Errorf("expected target or min and max")
+	}
+	return q, nil
+}
+
+// batchSolver solves batch queries, reusing the solutions table between them.
+type batchSolver struct {
+	digits  string // digits of the last search
+	depth   int64  // maxDepth of the last search
+	results SolutionSlice
+}
+
+// search returns all solutions for q. It reuses results of the previous search if
+// q has the same digits and depth, and the completed ranges within the common
+// prefix of tokens (see splitTokens) otherwise.
+func (b *batchSolver) search(q batchQuery) SolutionSlice {
+	if b.results != nil && q.Digits == b.digits && q.Depth == b.depth {
+		return b.results
+	}
+	if q.Depth != b.depth {
+		resetSolutions()
+	} else {
+		keepPrefix(commonPrefix(q.Digits, b.digits))
+	}
+	maxDepth = q.Depth
+	b.digits, b.depth = q.Digits, q.Depth
+	b.results, _ = search(q.Digits)
+	return b.results
+}
+
+// commonPrefix returns the number of the same leading tokens of digits a and b. Ranges of
+// separated and unseparated tokens have different solutions, so then it's zero.
+func commonPrefix(a, b string) int {
+	ta, sa := splitTokens(a)
+	tb, sb := splitTokens(b)
+	if sa != sb {
+		return 0
+	}
+	i := 0
+	for i < len(ta) && i < len(tb) && ta[i] == tb[i] {
+		i++
+	}
+	return i
+}
+
+// keepPrefix forgets all solutions and ranges, completed or not, using tokens after position n.
+func keepPrefix(n int) {
+	for s, nodes := range solutions {
+		if s.end > n {
+			stored -= len(nodes)
+			delete(solutions, s)
+		}
+	}
+	for r := range completed {
+		if r.End > n {
+			delete(completed, r)
+		}
+	}
+	for r := range inProgress {
+		if r.End > n {
+			delete(inProgress, r)
+		}
+	}
+}
+
+// inRangeSorted returns solutions from p with values in range (see inRange), sorted by
+// value. Solutions are filtered first, so huge values, which may overflow in Less, don't
+// spoil the order.
+func inRangeSorted(p SolutionSlice, min, max int64) SolutionSlice {
+	var result SolutionSlice
+	for _, s := range p {
+		if inRange(s.val, min, max) {
+			result = append(result, s)
+		}
+	}
+	result.Sort()
+	return result
+}
+
+// solve returns a record for query q, read from the given line of the input.
+func (b *batchSolver) solve(line int, query string) batchRecord {
+	r := batchRecord{Line: line, Query: query, Results: []batchResult{}}
+	q, err := parseBatchQuery(query)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Digits, r.Min, r.Max, r.Depth = q.Digits, q.Min, q.Max, q.Depth
+	for _, s := range inRangeSorted(b.search(q), q.Min, q.Max) {
+		if len(solutions[s]) == 0 {
+			continue
+		}
+		res := batchResult{Value: s.val.String()}
+		for _, n := range solutions[s] {
+			res.Formulas = append(res.Formulas, batchFormula{Formula: n.String(), Depth: n.Depth()})
+		}
+		sort.Slice(res.Formulas, func(i, j int) bool {
+			fi, fj := res.Formulas[i], res.Formulas[j]
+			return fi.Depth < fj.Depth || fi.Depth == fj.Depth && fi.Formula < fj.Formula
+		})
+		r.Results = append(r.Results, res)
+	}
+	return r
+}
+
+// RunBatch reads queries from r, one per line, and writes a JSON record per query to w.
+// Empty lines and lines starting with # are skipped.
+func RunBatch(r io.Reader, w io.Writer) error {
+	var b batchSolver
+	enc := json.NewEncoder(w)
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		query := strings.TrimSpace(sc.Text())
+		if query == "" || strings.HasPrefix(query, "#") {
+			continue
+		}
+		if err := enc.Encode(b.solve(line, query)); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBatch(t *testing.T) {
+	assert := assert.New(t)
+	in := "# comment\n123 6\n\n124 1 10\n124 7\n12x 5\n"
+	var out bytes.Buffer
+	assert.NoError(RunBatch(strings.NewReader(in), &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(lines, 4)
+	var records []batchRecord
+	for _, l := range lines {
+		var r batchRecord
+		assert.NoError(json.Unmarshal([]byte(l), &r))
+		records = append(records, r)
+	}
+	assert.Equal(2, records[0].Line)
+	assert.Equal("6", records[0].Results[0].Value)
+	n, err := FromInfix(records[0].Results[0].Formulas[0].Formula)
+	assert.NoError(err)
+	v, err := n.Eval()
+	assert.NoError(err)
+	assert.Equal(rational{6, 1}, v)
+
+	// Results reusing the common prefix "12" should be the same as for a fresh search.
+	maxDepth = 0
+	p, _ := Search("124")
+	var fresh []string
+	for _, s := range inRangeSorted(p, 1, 10) {
+		fresh = append(fresh, s.val.String())
+	}
+	var reused []string
+	for _, r := range records[1].Results {
+		reused = append(reused, r.Value)
+	}
+	assert.Equal(fresh, reused)
+	assert.Equal("7", records[2].Results[0].Value)
+
+	assert.Equal("invalid digits '12x'", records[3].Error)
+	assert.Empty(records[3].Results)
+}
+
+// batchRecords runs queries from in and returns their records.
+func batchRecords(t *testing.T, in string) []batchRecord {
+	assert := assert.New(t)
+	var out bytes.Buffer
+	assert.NoError(RunBatch(strings.NewReader(in), &out))
+	var records []batchRecord
+	for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var r batchRecord
+		assert.NoError(json.Unmarshal([]byte(l), &r))
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestRunBatchSeparated(t *testing.T) {
+	assert := assert.New(t)
+	records := batchRecords(t, "25,50,7 82\n25,5,7 37\n2,5 1,2\n99999999999999999999,1 5\n12345678901234567890 5\n25 7\n")
+	assert.Len(records, 6)
+	assert.Empty(records[0].Error)
+	assert.Equal("25,50,7", records[0].Digits)
+	assert.Equal("82", records[0].Results[0].Value)
+	assert.Equal("25 + 50 + 7", records[0].Results[0].Formulas[0].Formula)
+	// Only the token 25 is shared with the previous query
+	assert.Equal("37", records[1].Results[0].Value)
+	assert.Equal("25 + 5 + 7", records[1].Results[0].Formulas[0].Formula)
+	assert.Equal("cannot convert 1,2 to number", records[2].Error)
+	// Numbers which don't fit int64 fail their own queries only
+	assert.Equal("cannot convert 99999999999999999999 to number: value out of range", records[3].Error)
+	assert.Equal("cannot convert 12345678901234567890 to number: value out of range", records[4].Error)
+	assert.Equal("7", records[5].Results[0].Value)
+
+	assert.Equal(1, commonPrefix("25,50,7", "25,5,7"))
+	assert.Equal(2, commonPrefix("1234", "125"))
+	assert.Equal(0, commonPrefix("12", "1,2"))
+}
+
+func TestRunBatchBase(t *testing.T) {
+	assert := assert.New(t)
+	withBase(16, func() {
+		records := batchRecords(t, "fF 1e\na,b 15 16\n1g 5\n")
+		assert.Len(records, 3)
+		assert.Equal("ff", records[0].Digits)
+		assert.Equal(int64(30), records[0].Min)
+		assert.Equal("1e", records[0].Results[0].Value)
+		assert.Equal("f + f", records[0].Results[0].Formulas[0].Formula)
+		assert.Equal(int64(21), records[1].Min)
+		assert.Equal("15", records[1].Results[0].Value)
+		assert.Equal("a + b", records[1].Results[0].Formulas[0].Formula)
+		assert.Equal("invalid digits '1g'", records[2].Error)
+	})
+}
@@ -0,0 +1,294 @@
+// This file contains an on-disk cache of search results.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheVersion should be incremented whenever the cache format or the meaning of the
+// stored data changes; files with other versions are ignored.
+const cacheVersion = 3
+
+// cacheEntry stores a Solution with its formulas, which are indexes of cacheNodes.
+type cacheEntry struct {
+	Val        string
+	Start, End int
+	Formulas   []int
+}
+
+// cacheNode stores a Node: a leaf written as Lit if Op is OpNull, and otherwise Op with
+// the indexes of the nodes of its operands, which come before it. Right is -1 for unary
+// operators. Formulas share subtrees, which are stored only once, and shared again when
+// decoded. Op values are stored as numbers, so cacheVersion changes with them.
+type cacheNode struct {
+	Op          Op
+	Lit         string
+	Left, Right int
+}
+
+// cacheFile is the content of a cache file: results of Search for Digits, made with
+// operators Ops and maxDepth = Depth, and the solutions table for all ranges of digits.
+type cacheFile struct {
+	Version int
+	Digits  string
+	Ops     string
+	Depth   int64
+	Results []cacheEntry
+	Table   []cacheEntry
+	Nodes   []cacheNode
+}
+
+// cachePath returns the name of the cache file in dir for digits and current search settings.
+// Digits may be long and contain separators, so only their hash goes into the name.
+func cachePath(dir, digits string) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d", digits, opsKey(), maxDepth)))
+	return filepath.Join(dir, fmt.Sprintf("%x.gob", h[:16]))
+}
+
+// SearchCached is like Search, but loads the results from dir if they were saved there by
+// an earlier search with the same digits, operators and depth, and saves them otherwise.
+func SearchCached(dir, digits string) (SolutionSlice, *Stats, error) {
+	path := cachePath(dir, digits)
+	t := time.Now()
+	if p, err := loadCache(path, digits); err == nil {
+		stats = newStats()
+		stats.Peak = stored
+		stats.Elapsed = time.Since(t)
+		return p, stats, nil
+	} else if !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "ignoring cache: %s\n", err)
+	}
+	p, st := Search(digits)
+	return p, st, saveCache(path, digits, p)
+}
+
+// newCacheEntry converts s to cacheEntry without formulas.
+func newCacheEntry(s Solution) cacheEntry {
+	return cacheEntry{Val: s.val.String(), Start: s.start, End: s.end}
+}
+
+// solution converts e back to Solution.
+func (e cacheEntry) solution() (Solution, error) {
+	v, err := parseValue(e.Val)
+	if err != nil {
+		return NoSolution, err
+	}
+	return Solution{val: v, start: e.Start, end: e.End}, nil
+}
+
+// encodeSolutions converts p to cache entries without formulas.
+func encodeSolutions(p SolutionSlice) []cacheEntry {
+	var result []cacheEntry
+	for _, s := range p {
+		result = append(result, newCacheEntry(s))
+	}
+	return result
+}
+
+// decodeSolutions is an opposite of encodeSolutions.
+func decodeSolutions(entries []cacheEntry) (SolutionSlice, error) {
+	var p SolutionSlice
+	for _, e := range entries {
+		s, err := e.solution()
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, s)
+	}
+	return p, nil
+}
+
+// tableEncoder converts solutions with their formulas to cache entries and nodes, storing
+// every solution and every node once.
+type tableEncoder struct {
+	entries []cacheEntry
+	nodes   []cacheNode
+	index   map[Solution]int
+	nodeIdx map[*Node]int
+}
+
+func newTableEncoder() *tableEncoder {
+	return &tableEncoder{index: make(map[Solution]int), nodeIdx: make(map[*Node]int)}
+}
+
+// add returns the index of the entry for s, adding it, with its formulas if withFormulas
+// is true, if it's not there yet.
+func (e *tableEncoder) add(s Solution, withFormulas bool) int {
+	if i, ok := e.index[s]; ok {
+		return i
+	}
+	c := newCacheEntry(s)
+	if withFormulas {
+		for _, n := range solutions[s] {
+			c.Formulas = append(c.Formulas, e.node(n))
+		}
+	}
+	e.index[s] = len(e.entries)
+	e.entries = append(e.entries, c)
+	return e.index[s]
+}
+
+// node returns the index of n, adding it and its subtrees if they are not there yet.
+func (e *tableEncoder) node(n *Node) int {
+	if i, ok := e.nodeIdx[n]; ok {
+		return i
+	}
+	c := cacheNode{Op: n.op, Left: -1, Right: -1}
+	if n.op == OpNull {
+		c.Lit = n.literal()
+	} else {
+		c.Left = e.node(n.left)
+		if n.right != nil {
+			c.Right = e.node(n.right)
+		}
+	}
+	e.nodeIdx[n] = len(e.nodes)
+	e.nodes = append(e.nodes, c)
+	return e.nodeIdx[n]
+}
+
+// encodeTable converts the solutions table to cache entries and nodes.
+func encodeTable() ([]cacheEntry, []cacheNode) {
+	e := newTableEncoder()
+	for s := range solutions {
+		e.add(s, true)
+	}
+	return e.entries, e.nodes
+}
+
+// decodeNodes is an opposite of tableEncoder.node: it returns the nodes for all cache
+// nodes.
+func decodeNodes(nodes []cacheNode) ([]*Node, error) {
+	result := make([]*Node, len(nodes))
+	operand := func(i, j int) (*Node, error) {
+		if j < 0 || j >= i {
+			return nil, fmt.Errorf("%w: node %d has operand %d", ErrInvalidNode, i, j)
+		}
+		return result[j], nil
+	}
+	for i, c := range nodes {
+		if c.Op == OpNull {
+			n, err := newLitNode(c.Lit)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = n
+			continue
+		}
+		n := &Node{op: c.Op}
+		var err error
+		if n.left, err = operand(i, c.Left); err != nil {
+			return nil, err
+		}
+		if c.Right >= 0 {
+			if n.right, err = operand(i, c.Right); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := opNames[c.Op]; !ok || !n.valid() {
+			return nil, fmt.Errorf("%w: node %d", ErrInvalidNode, i)
+		}
+		result[i] = n
+	}
+	return result, nil
+}
+
+// decodeTable replaces the solutions table with the one stored in entries and nodes,
+// and returns solutions of all entries in the same order.
+func decodeTable(entries []cacheEntry, nodes []cacheNode) (SolutionSlice, error) {
+	resetSolutions()
+	decoded, err := decodeNodes(nodes)
+	if err != nil {
+		return nil, err
+	}
+	var p SolutionSlice
+	for _, e := range entries {
+		s, err := e.solution()
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range e.Formulas {
+			if i < 0 || i >= len(decoded) {
+				return nil, fmt.Errorf("%w: no node %d", ErrInvalidNode, i)
+			}
+			solutions[s] = append(solutions[s], decoded[i])
+			stored++
+		}
+		p = append(p, s)
+	}
+	return p, nil
+}
+
+// writeGob atomically writes v encoded by gob to path, creating the directory if needed.
+func writeGob(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := gob.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// readGob reads v encoded by gob from path.
+func readGob(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := gob.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("cannot read %s: %s", path, err)
+	}
+	return nil
+}
+
+// saveCache saves results p of the search for digits, and the solutions table, to path.
+func saveCache(path, digits string, p SolutionSlice) error {
+	table, nodes := encodeTable()
+	return writeGob(path, &cacheFile{
+		Version: cacheVersion,
+		Digits:  digits,
+		Ops:     opsKey(),
+		Depth:   maxDepth,
+		Results: encodeSolutions(p),
+		Table:   table,
+		Nodes:   nodes,
+	})
+}
+
+// loadCache loads the solutions table from path, and returns the search results stored there.
+func loadCache(path, digits string) (SolutionSlice, error) {
+	var c cacheFile
+	if err := readGob(path, &c); err != nil {
+		return nil, err
+	}
+	if c.Version != cacheVersion {
+		return nil, fmt.Errorf("%s has version %d, expected %d", path, c.Version, cacheVersion)
+	}
+	if c.Digits != digits || c.Ops != opsKey() || c.Depth != maxDepth {
+		return nil, fmt.Errorf("%s was saved for another search", path)
+	}
+	if _, err := decodeTable(c.Table, c.Nodes); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %s", path, err)
+	}
+	p, err := decodeSolutions(c.Results)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %s", path, err)
+	}
+	return p, nil
+}
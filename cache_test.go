@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchCached(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	maxDepth = 2
+	p1, _, err := SearchCached(dir, "123")
+	assert.NoError(err)
+	formulas := p1.Formulas(rational{7, 1})
+	assert.NotEmpty(formulas)
+	_, err = os.Stat(cachePath(dir, "123"))
+	assert.NoError(err)
+
+	resetSolutions()
+	p2, st, err := SearchCached(dir, "123")
+	assert.NoError(err)
+	assert.Equal(0, st.Added, "results should be loaded from the cache")
+	assert.Equal(len(p1), len(p2))
+	cached := p2.Formulas(rational{7, 1})
+	assert.Equal(len(formulas), len(cached))
+	for i := range formulas {
+		assert.True(formulas[i].Equal(cached[i]))
+	}
+
+	_, err = loadCache(cachePath(dir, "123"), "124")
+	assert.Error(err)
+	path := cachePath(dir, "123")
+	maxDepth = 3
+	assert.NotEqual(path, cachePath(dir, "123"))
+	path = cachePath(dir, "1/2, 34")
+	assert.Equal(dir, filepath.Dir(path))
+	assert.False(strings.ContainsAny(filepath.Base(path), ", "))
+	maxDepth = 0
+}
+
+func TestOpsKeyKeepsOps(t *testing.T) {
+	assert := assert.New(t)
+	defer func(b []Op) { binaryOps = b }(binaryOps)
+	binaryOps = append(make([]Op, 0, 10), OpAdd, OpSub)
+	spare := binaryOps[:3]
+	spare[2] = OpMul
+	opsKey()
+	assert.Equal(OpMul, spare[2], "opsKey should not write into binaryOps")
+}
+
+// tableNodes returns the number of distinct nodes in the formulas of the solutions table.
+func tableNodes() int {
+	seen := make(map[*Node]bool)
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		if n == nil || seen[n] {
+			return
+		}
+		seen[n] = true
+		visit(n.left)
+		visit(n.right)
+	}
+	for _, formulas := range solutions {
+		for _, n := range formulas {
+			visit(n)
+		}
+	}
+	return len(seen)
+}
+
+func TestCacheSharesSubtrees(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	maxDepth = 3
+	defer func() { maxDepth = 0 }()
+	p1, _, err := SearchCached(dir, "1234")
+	assert.NoError(err)
+	nodes, formulas := tableNodes(), stored
+
+	p2, err := loadCache(cachePath(dir, "1234"), "1234")
+	assert.NoError(err)
+	assert.Equal(len(p1), len(p2))
+	assert.Equal(formulas, stored)
+	assert.Equal(nodes, tableNodes(), "subtrees should be shared as they were before saving")
+	for _, s := range p2 {
+		for _, n := range solutions[s] {
+			assert.True(n.valid())
+			v, err := n.Eval()
+			if assert.NoError(err, "%s", n) {
+				assert.True(v.Equal(s.val), "%s", n)
+			}
+		}
+	}
+
+	var c cacheFile
+	assert.NoError(readGob(cachePath(dir, "1234"), &c))
+	c.Nodes = append(c.Nodes, cacheNode{Op: OpAdd, Left: len(c.Nodes), Right: 0})
+	c.Table[0].Formulas = append(c.Table[0].Formulas, len(c.Nodes)-1)
+	_, err = decodeTable(c.Table, c.Nodes)
+	assert.ErrorIs(err, ErrInvalidNode)
+}
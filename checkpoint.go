@@ -0,0 +1,201 @@
+// This file contains checkpoints, which allow to resume long searches.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStopped is returned by SearchResumable if the search was stopped before completion.
+var ErrStopped = errors.New("search stopped")
+
+// checkpointRange stores results of FindAllSolutions for tokens[Start:End] as indexes of
+// entries in checkpointFile.Table. If the range is not Done, these are results of the
+// splits before Split, and of the first Pairs pairs of solutions for Split (see rangeProgress).
+type checkpointRange struct {
+	Start, End   int
+	Done         bool
+	Split, Pairs int
+	Results      []int
+}
+
+// checkpointFile is the content of a checkpoint: results for all completed ranges of
+// Digits in the order of completion, the progress of ranges which are not completed,
+// and the formulas for solutions of these ranges. The header is the same as in cacheFile.
+type checkpointFile struct {
+	Version int
+	Digits  string
+	Ops     string
+	Depth   int64
+	Ranges  []checkpointRange
+	Table   []cacheEntry
+	Nodes   []cacheNode
+}
+
+// Checkpointer periodically saves completed ranges of the search for Digits to Path,
+// together with the progress of ranges being searched, so that the search can be
+// resumed after a crash or an interrupt from where it left.
+type Checkpointer struct {
+	Path     string
+	Digits   string
+	Interval time.Duration // save at most once per Interval
+	Budget   time.Duration // if positive, stop the search after this time, not counting loading the checkpoint
+	deadline time.Time
+	last     time.Time
+	ranges   []Range // completed ranges, in the order of completion
+	stop     atomic.Bool
+	halted   bool // the search was stopped, and its results are incomplete
+}
+
+// checkpointer, if not nil, is notified by FindAllSolutions about every completed range.
+var checkpointer *Checkpointer
+
+// Stop asks the search to save a checkpoint and stop as soon as possible.
+// It can be called from another goroutine, e.g. a signal handler.
+func (c *Checkpointer) Stop() {
+	c.stop.Store(true)
+}
+
+// done is called when the range r is completed. It records r, and saves a checkpoint
+// when it's time.
+func (c *Checkpointer) done(r Range) {
+	if c == nil {
+		return
+	}
+	c.ranges = append(c.ranges, r)
+	c.progress()
+}
+
+// progress is called often during the search. It saves a checkpoint when it's time.
+func (c *Checkpointer) progress() {
+	if c == nil || c.stopped() {
+		return
+	}
+	if time.Since(c.last) >= c.Interval {
+		c.checkpoint()
+	}
+}
+
+// checkpoint saves a checkpoint, reporting errors to stderr, as the search goes on anyway.
+func (c *Checkpointer) checkpoint() {
+	if err := c.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot save checkpoint: %s\n", err)
+	}
+	c.last = time.Now()
+}
+
+// stopped returns true if the search was asked to stop (see Stop) or its budget is over.
+// Then FindAllSolutions returns nil, keeping the progress of the ranges which are not
+// completed, so it's checked often during the search.
+func (c *Checkpointer) stopped() bool {
+	if c == nil {
+		return false
+	} else if !c.halted {
+		c.halted = c.stop.Load() || (!c.deadline.IsZero() && time.Now().After(c.deadline))
+	}
+	return c.halted
+}
+
+// save writes completed ranges, the progress of other ranges, and the solutions table,
+// which has formulas only for solutions of these ranges, to c.Path.
+func (c *Checkpointer) save() error {
+	f := checkpointFile{
+		Version: cacheVersion,
+		Digits:  c.Digits,
+		Ops:     opsKey(),
+		Depth:   maxDepth,
+	}
+	// Most results are in the table anyway, so they are stored only once
+	e := newTableEncoder()
+	for s := range solutions {
+		e.add(s, true)
+	}
+	indexes := func(p SolutionSlice) []int {
+		var result []int
+		for _, s := range p {
+			result = append(result, e.add(s, false))
+		}
+		return result
+	}
+	for _, r := range c.ranges {
+		f.Ranges = append(f.Ranges, checkpointRange{
+			Start: r.Start, End: r.End, Done: true, Results: indexes(completed[r]),
+		})
+	}
+	for r, pr := range inProgress {
+		f.Ranges = append(f.Ranges, checkpointRange{
+			Start: r.Start, End: r.End, Split: pr.split, Pairs: pr.pairs, Results: indexes(uniq(pr.results)),
+		})
+	}
+	f.Table, f.Nodes = e.entries, e.nodes
+	return writeGob(c.Path, &f)
+}
+
+// load restores completed ranges, the progress of other ranges, and the solutions table
+// from c.Path.
+func (c *Checkpointer) load() error {
+	var f checkpointFile
+	if err := readGob(c.Path, &f); err != nil {
+		return err
+	}
+	if f.Version != cacheVersion {
+		return fmt.Errorf("%s has version %d, expected %d", c.Path, f.Version, cacheVersion)
+	}
+	if f.Digits != c.Digits || f.Ops != opsKey() || f.Depth != maxDepth {
+		return fmt.Errorf("%s was saved for another search", c.Path)
+	}
+	table, err := decodeTable(f.Table, f.Nodes)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %s", c.Path, err)
+	}
+	for _, r := range f.Ranges {
+		var p SolutionSlice
+		for _, i := range r.Results {
+			if i < 0 || i >= len(table) {
+				return fmt.Errorf("cannot read %s: no result %d", c.Path, i)
+			}
+			p = append(p, table[i])
+		}
+		rng := Range{r.Start, r.End}
+		if r.Done {
+			completed[rng] = p
+			c.ranges = append(c.ranges, rng)
+		} else {
+			inProgress[rng] = &rangeProgress{split: r.Split, pairs: r.Pairs, results: p}
+		}
+	}
+	return nil
+}
+
+// SearchResumable is like Search, but saves checkpoints with c while searching. If resume
+// is true, the search continues from the checkpoint saved at c.Path, if it exists.
+// If the search is stopped (see Checkpointer), it returns ErrStopped.
+func SearchResumable(c *Checkpointer, resume bool) (SolutionSlice, *Stats, error) {
+	resetSolutions()
+	c.ranges = nil
+	if resume {
+		if err := c.load(); err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+	checkpointer = c
+	c.last = time.Now()
+	c.halted = false
+	c.deadline = time.Time{}
+	if c.Budget > 0 {
+		// Loading a big checkpoint takes a while, but every run should make some progress
+		c.deadline = c.last.Add(c.Budget)
+	}
+	defer func() { checkpointer = nil }()
+	p, st := search(c.Digits)
+	tokens, _ := splitTokens(c.Digits)
+	if _, ok := completed[Range{0, len(tokens)}]; !ok {
+		// Formulas found in the stopped ranges are saved too, and don't have to be built again
+		c.checkpoint()
+		return nil, nil, ErrStopped
+	}
+	return p, st, c.save()
+}
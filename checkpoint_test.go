@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchResumable(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 0
+	expected, full := Search("1234")
+	path := filepath.Join(t.TempDir(), "1234.checkpoint")
+
+	// A checkpoint of the search interrupted after the first three digits
+	keepPrefix(3)
+	c := &Checkpointer{Path: path, Digits: "1234", Interval: time.Hour}
+	for r := range completed {
+		c.ranges = append(c.ranges, r)
+	}
+	assert.NoError(c.save())
+
+	p, st, err := SearchResumable(c, true)
+	assert.NoError(err)
+	assert.True(st.Added < full.Added, "completed ranges should not be searched again")
+	assert.Equal(len(expected), len(p))
+
+	c = &Checkpointer{Path: path, Digits: "1235", Interval: time.Hour}
+	_, _, err = SearchResumable(c, true)
+	assert.Error(err)
+}
+
+func TestSearchStopped(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 0
+	path := filepath.Join(t.TempDir(), "12345.checkpoint")
+
+	c := &Checkpointer{Path: path, Digits: "12345", Interval: time.Hour, Budget: time.Nanosecond}
+	_, _, err := SearchResumable(c, false)
+	assert.Equal(ErrStopped, err)
+	assert.Empty(completed)
+	_, err = os.Stat(path)
+	assert.NoError(err, "checkpoint should be saved on stop")
+
+	// The whole search takes seconds, mostly in the top range, which must be interrupted too
+	t0 := time.Now()
+	c = &Checkpointer{Path: path, Digits: "12345", Interval: time.Hour, Budget: 100 * time.Millisecond}
+	_, _, err = SearchResumable(c, false)
+	assert.Equal(ErrStopped, err)
+	assert.True(time.Since(t0) < time.Second, "search should stop soon after the deadline")
+	_, ok := completed[Range{0, 5}]
+	assert.False(ok)
+	assert.NotEmpty(inProgress[Range{0, 5}].results, "the top range should be stopped in progress")
+
+	c.Budget = 0
+	_, _, err = SearchResumable(c, true)
+	assert.NoError(err)
+}
+
+func TestSearchResumedInSteps(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 0
+	expected, _ := Search("1234")
+	formulas := make(map[string]string)
+	for _, s := range expected {
+		formulas[s.val.String()] = solutions[s][0].String()
+	}
+	path := filepath.Join(t.TempDir(), "1234.checkpoint")
+
+	// Every run is much shorter than the top range takes, so it continues that range
+	var p SolutionSlice
+	err := ErrStopped
+	runs := 0
+	for ; err == ErrStopped && runs < 100; runs++ {
+		c := &Checkpointer{Path: path, Digits: "1234", Interval: time.Hour, Budget: 20 * time.Millisecond}
+		p, _, err = SearchResumable(c, true)
+	}
+	assert.NoError(err)
+	assert.True(runs > 2, "the search should be stopped several times")
+	assert.Equal(len(expected), len(p))
+	for _, s := range p {
+		assert.Equal(formulas[s.val.String()], solutions[s][0].String())
+	}
+}
+
+func TestCheckpointSharesSubtrees(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 2
+	defer func() { maxDepth = 0 }()
+	path := filepath.Join(t.TempDir(), "12345.checkpoint")
+	c := &Checkpointer{Path: path, Digits: "12345", Interval: time.Hour, Budget: 50 * time.Millisecond}
+	_, _, err := SearchResumable(c, false)
+	if !assert.Equal(ErrStopped, err) {
+		return
+	}
+	nodes, formulas := tableNodes(), stored
+
+	resetSolutions()
+	c = &Checkpointer{Path: path, Digits: "12345"}
+	assert.NoError(c.load())
+	assert.Equal(formulas, stored)
+	assert.Equal(nodes, tableNodes(), "resumed formulas should share subtrees like the search does")
+}
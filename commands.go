@@ -0,0 +1,189 @@
+// This file contains sub-commands of the command line tool.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// evalCmd evaluates a formula: digits eval [--steps] [--notation prefix|postfix|infix] "<formula>"
+func evalCmd(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	steps := fs.Bool("steps", false, "print every intermediate sub-formula with its value")
+	notation := fs.String("notation", "prefix", "notation of the formula: prefix, postfix or infix")
+	fs.Parse(args)
+	n, err := parseFormula(*notation, strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+	if !*steps {
+		v, err := n.Eval()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s = %s\n", n, v)
+		return nil
+	}
+	trace, err := n.Trace()
+	i := 0
+	for _, s := range trace {
+		if s.Node.op == OpNull {
+			continue
+		}
+		i++
+		fmt.Printf("%3d. %s = %s\n", i, s.Node, s.Val)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s = %s\n", n, trace[len(trace)-1].Val)
+	return nil
+}
+
+// parseCmd prints a formula in all supported notations:
+// digits parse [--notation prefix|postfix|infix] "<formula>"
+func parseCmd(args []string) error {
+	fs := flag.NewFlagSet("parse", flag.ExitOnError)
+	notation := fs.String("notation", "prefix", "notation of the formula: prefix, postfix or infix")
+	fs.Parse(args)
+	n, err := parseFormula(*notation, strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("infix:   %s\n", n)
+	fmt.Printf("prefix:  %s\n", n.ToPolish())
+	fmt.Printf("postfix: %s\n", n.ToRPN())
+	return nil
+}
+
+// dotCmd prints formulas as a Graphviz graph. The formulas are either given in Polish
+// notation: digits dot [--values] "<formula>", or found by a search:
+// digits dot [--values] --digits D --target V [--depth N]
+func dotCmd(args []string) error {
+	fs := flag.NewFlagSet("dot", flag.ExitOnError)
+	values := fs.Bool("values", false, "annotate operators with intermediate values")
+	digits := fs.String("digits", "", "search formulas made of these digits")
+	target := fs.String("target", "", "value of the formulas to search for")
+	depth := fs.Int64("depth", 0, "if positive, find all formulas up to this depth")
+	fs.Parse(args)
+	var formulas []*Node
+	if *digits == "" {
+		n, err := FromPolish(strings.Join(fs.Args(), " "))
+		if err != nil {
+			return err
+		}
+		formulas = append(formulas, n)
+	} else {
+		v, err := newRationalFromString(*target)
+		if err != nil {
+			return err
+		}
+		maxDepth = *depth
+		p, _ := Search(*digits)
+		if formulas = p.Formulas(v); len(formulas) == 0 {
+			return fmt.Errorf("no formulas for %s from %s", v, *digits)
+		}
+	}
+	return WriteDot(os.Stdout, formulas, *values)
+}
+
+// batchCmd solves queries read from files or stdin: digits batch [file...]
+// See RunBatch for the format.
+func batchCmd(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return RunBatch(os.Stdin, os.Stdout)
+	}
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = RunBatch(f, os.Stdout)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ticketCmd finds equations for "lucky tickets": digits ticket [--half] [--first] digits...
+func ticketCmd(args []string) error {
+	fs := flag.NewFlagSet("ticket", flag.ExitOnError)
+	half := fs.Bool("half", false, "only split digits in the middle")
+	first := fs.Bool("first", false, "only print the first equation for every ticket")
+	depth := fs.Int64("depth", 0, "if positive, consider formulas up to this depth")
+	fs.Parse(args)
+	maxDepth = *depth
+	for _, digits := range fs.Args() {
+		// A bad ticket doesn't stop the others
+		eqs, err := FindTickets(digits, *half)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", digits, err)
+			continue
+		} else if len(eqs) == 0 {
+			fmt.Printf("%s: no solution\n", digits)
+			continue
+		}
+		if *first {
+			eqs = eqs[:1]
+		}
+		for _, e := range eqs {
+			fmt.Printf("%s: %s\n", digits, e)
+		}
+	}
+	return nil
+}
+
+// equationsCmd finds all equations made of digits, optionally with a fixed position of "=":
+// digits equations [--depth N] digits...
+func equationsCmd(args []string) error {
+	fs := flag.NewFlagSet("equations", flag.ExitOnError)
+	depth := fs.Int64("depth", 0, "if positive, consider formulas up to this depth")
+	fs.Parse(args)
+	maxDepth = *depth
+	for _, digits := range fs.Args() {
+		eqs, err := FindEquations(digits)
+		if err != nil {
+			return err
+		}
+		if len(eqs) == 0 {
+			fmt.Printf("%s: no solution\n", digits)
+		}
+		for _, e := range eqs {
+			fmt.Printf("[%2d|%2d] %s\n", e.Left.Depth(), e.Right.Depth(), e)
+		}
+	}
+	return nil
+}
+
+// approxCmd finds formulas closest to a target number:
+// digits approx [--target pi|e|phi|decimal] [--top N] [--depth N] [--ops list] digits...
+func approxCmd(args []string) error {
+	fs := flag.NewFlagSet("approx", flag.ExitOnError)
+	target := fs.String("target", "pi", "number to approximate: pi, e, phi or a decimal")
+	top := fs.Int("top", 10, "number of approximations to print for every digits")
+	depth := fs.Int64("depth", 0, "if positive, consider formulas up to this depth")
+	ops := fs.String("ops", "", "comma-separated optional operators to use, e.g. !!,subfact,#")
+	fs.Parse(args)
+	t, err := parseTarget(*target)
+	if err != nil {
+		return err
+	}
+	if *ops != "" {
+		if err := enableOps(*ops); err != nil {
+			return err
+		}
+	}
+	maxDepth = *depth
+	for _, digits := range fs.Args() {
+		for _, a := range FindApproximations(digits, t, *top) {
+			fmt.Printf("%s: %s\n", digits, a)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+// This file contains complex rational values like 1/2 + 3i, used with --complex.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// cplx stores re + im*i with rational re and im.
+type cplx struct {
+	re, im rational
+}
+
+// newCplx creates a normalized complex value re + im*i.
+func newCplx(re, im rational) cplx {
+	return cplx{re: re.normalize(), im: im.normalize()}
+}
+
+// ratCplx converts r to cplx.
+func ratCplx(r rational) cplx {
+	return newCplx(r, rational{0, 1})
+}
+
+// toCplx converts complex values and rationals to cplx.
+func toCplx(v Value) (cplx, bool) {
+	switch v := v.(type) {
+	case cplx:
+		return v, true
+	case rational:
+		return ratCplx(v), true
+	}
+	return cplx{}, false
+}
+
+// newCplxFromString is an opposite of cplx.String: it parses values like 3, -1/2, 2i,
+// -i or 1/2 - 3/4i.
+func newCplxFromString(s string) (cplx, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasSuffix(s, "i") {
+		r, err := newRationalFromString(s)
+		return ratCplx(r), err
+	}
+	re, im := "0", strings.TrimSuffix(s, "i")
+	if k := strings.LastIndexAny(im, "+-"); k > 0 {
+		re, im = strings.TrimSpace(im[:k]), strings.Replace(im[k:], " ", "", -1)
+	}
+	im = strings.TrimPrefix(im, "+")
+	if im == "" || im == "-" {
+		im += "1"
+	}
+	r, err := newRationalFromString(re)
+	if err != nil {
+		return cplx{}, err
+	}
+	r1, err := newRationalFromString(im)
+	if err != nil {
+		return cplx{}, err
+	}
+	return newCplx(r, r1), nil
+}
+
+// real returns z as rational, and false if z has an imaginary part.
+func (z cplx) real() (rational, bool) {
+	return z.re, z.im.Zero()
+}
+
+func (z cplx) String() string {
+	if z.im.Zero() {
+		return z.re.String()
+	}
+	im, sign := z.im, " + "
+	if im.Negative() {
+		im, sign = im.Minus(), " - "
+	}
+	i := "i"
+	if !im.One() {
+		i = im.String() + "i"
+	}
+	if z.re.Zero() && sign == " - " {
+		return "-" + i
+	} else if z.re.Zero() {
+		return i
+	}
+	return fmt.Sprintf("%s%s%s", z.re, sign, i)
+}
+
+// PerformUnary is an implementation of Value.PerformUnary. Operators other than minus,
+// sqrt, reciprocal and absolute value are only defined for real z.
+func (z cplx) PerformUnary(op Op) (Value, error) {
+	var r cplx
+	var err error
+	switch op {
+	case OpMinus:
+		return newCplx(z.re.Minus(), z.im.Minus()), nil
+	case OpSqrt:
+		r, err = z.sqrt()
+	case OpRecip:
+		r, err = ratCplx(rational{1, 1}).div(z)
+	case OpAbs:
+		r, err = z.abs()
+	default:
+		re, ok := z.real()
+		if !ok {
+			return cplx{}, newOpError(ErrDomain, op, z)
+		}
+		var v Value
+		if v, err = re.PerformUnary(op); err == nil {
+			r = ratCplx(v.(rational))
+		}
+	}
+	if err != nil {
+		return cplx{}, rewrap(err, op, z)
+	}
+	return r, nil
+}
+
+// PerformBinary is an implementation of Value.PerformBinary. Operators other than
+// arithmetic and roots are only defined for real arguments.
+func (z cplx) PerformBinary(op Op, v Value) (Value, error) {
+	w, ok := toCplx(v)
+	if !ok {
+		return cplx{}, newOpError(ErrMismatch, op, z, v)
+	}
+	var r cplx
+	var err error
+	var calc ratCalc
+	switch op {
+	case OpAdd:
+		r = newCplx(calc.add(z.re, w.re), calc.add(z.im, w.im))
+	case OpSub:
+		r = newCplx(calc.add(z.re, w.re.Minus()), calc.add(z.im, w.im.Minus()))
+	case OpMul:
+		r, err = z.mul(w)
+	case OpDiv:
+		r, err = z.div(w)
+	case OpPow:
+		r, err = z.pow(w)
+	case OpRoot:
+		var e cplx
+		if z.Zero() {
+			err = newOpError(ErrDomain, op, z, w)
+		} else if e, err = ratCplx(rational{1, 1}).div(z); err == nil {
+			r, err = w.pow(e)
+		}
+	default:
+		re, ok1 := z.real()
+		re1, ok2 := w.real()
+		if !ok1 || !ok2 {
+			return cplx{}, newOpError(ErrDomain, op, z, w)
+		}
+		var v Value
+		if v, err = re.PerformBinary(op, re1); err == nil {
+			r = ratCplx(v.(rational))
+		}
+	}
+	if err == nil {
+		err = calc.err
+	}
+	if err != nil {
+		return cplx{}, rewrap(err, op, z, w)
+	}
+	return r, nil
+}
+
+func (z cplx) mul(w cplx) (cplx, error) {
+	var calc ratCalc
+	re := calc.add(calc.mul(z.re, w.re), calc.mul(z.im, w.im).Minus())
+	im := calc.add(calc.mul(z.re, w.im), calc.mul(z.im, w.re))
+	return newCplx(re, im), calc.err
+}
+
+// norm returns re^2 + im^2.
+func (z cplx) norm() (rational, error) {
+	var calc ratCalc
+	n := calc.add(calc.mul(z.re, z.re), calc.mul(z.im, z.im))
+	return n, calc.err
+}
+
+// div returns z * conj(w) / norm(w). Real w divides z directly, as norm(w) = w^2 may
+// overflow.
+func (z cplx) div(w cplx) (cplx, error) {
+	if w.Zero() {
+		return cplx{}, newOpError(ErrDivByZero, OpDiv, z, w)
+	} else if re, ok := w.real(); ok {
+		var calc ratCalc
+		re, im := calc.do(z.re.Div, re), calc.do(z.im.Div, re)
+		return newCplx(re, im), calc.err
+	}
+	num, err := z.mul(newCplx(w.re, w.im.Minus()))
+	if err != nil {
+		return cplx{}, err
+	}
+	n, err := w.norm()
+	if err != nil {
+		return cplx{}, err
+	}
+	var calc ratCalc
+	re, im := calc.do(num.re.Div, n), calc.do(num.im.Div, n)
+	return newCplx(re, im), calc.err
+}
+
+// abs returns |z|, if it's rational.
+func (z cplx) abs() (cplx, error) {
+	n, err := z.norm()
+	if err != nil {
+		return cplx{}, err
+	}
+	a, err := n.Sqrt()
+	return ratCplx(a), err
+}
+
+// sqrt returns the square root x + yi of z with x >= 0, if it's a complex rational:
+// x = sqrt((|z| + re) / 2) and y = ±sqrt((|z| - re) / 2) with the sign of im.
+func (z cplx) sqrt() (cplx, error) {
+	if re, ok := z.real(); ok && !re.Negative() {
+		r, err := re.Sqrt()
+		return ratCplx(r), err
+	}
+	m, err := z.abs()
+	if err != nil {
+		return cplx{}, err
+	}
+	var calc ratCalc
+	half := rational{1, 2}
+	x2 := calc.mul(calc.add(m.re, z.re), half)
+	y2 := calc.mul(calc.add(m.re, z.re.Minus()), half)
+	if calc.err != nil {
+		return cplx{}, calc.err
+	}
+	x, err := x2.Sqrt()
+	if err != nil {
+		return cplx{}, err
+	}
+	y, err := y2.Sqrt()
+	if err != nil {
+		return cplx{}, err
+	}
+	if z.im.Negative() {
+		y = y.Minus()
+	}
+	return newCplx(x, y), nil
+}
+
+// maxCplxPower limits integer powers of non-real values, which overflow soon anyway.
+const maxCplxPower = 64
+
+// pow returns z^w for rational w. Non-real z and negative real z can only be raised to
+// integer powers, or to powers with denominator 2 through sqrt.
+func (z cplx) pow(w cplx) (cplx, error) {
+	e, ok := w.real()
+	if !ok {
+		return cplx{}, newOpError(ErrNotRational, OpPow, z, w)
+	}
+	if re, ok := z.real(); ok {
+		r, err := re.Pow(e)
+		if err == nil || !errors.Is(err, ErrDomain) || !re.Negative() || e.d != 2 {
+			return ratCplx(r), err
+		}
+	}
+	if e.d == 2 {
+		s, err := z.sqrt()
+		if err != nil {
+			return cplx{}, err
+		}
+		return s.pow(ratCplx(rational{e.n, 1}))
+	} else if e.d != 1 {
+		return cplx{}, newOpError(ErrNotRational, OpPow, z, w)
+	} else if e.n < 0 {
+		inv, err := ratCplx(rational{1, 1}).div(z)
+		if err != nil {
+			return cplx{}, err
+		}
+		return inv.pow(ratCplx(e.Minus()))
+	} else if e.n > maxCplxPower {
+		return cplx{}, newOpError(ErrOverflow, OpPow, z, w)
+	}
+	p := ratCplx(rational{1, 1})
+	for i := int64(0); i < e.n; i++ {
+		var err error
+		if p, err = p.mul(z); err != nil {
+			return cplx{}, err
+		}
+	}
+	return p, nil
+}
+
+// Equal is an implementation of Value.Equal
+func (z cplx) Equal(v Value) bool {
+	w, ok := toCplx(v)
+	return ok && z == w
+}
+
+// Less is an implementation of Value.Less. Only real values are ordered: if z or v
+// is not real, Less returns false (see ordered).
+func (z cplx) Less(v Value) bool {
+	w, ok := toCplx(v)
+	if !ok {
+		return false
+	}
+	re, ok1 := z.real()
+	re1, ok2 := w.real()
+	return ok1 && ok2 && re.isLess(re1)
+}
+
+func (z cplx) IsInteger() bool {
+	re, ok := z.real()
+	return ok && re.IsInteger()
+}
+
+func (z cplx) Negative() bool {
+	re, ok := z.real()
+	return ok && re.Negative()
+}
+
+func (z cplx) Even() bool {
+	re, ok := z.real()
+	return ok && re.Even()
+}
+
+func (z cplx) Zero() bool {
+	return z.re.Zero() && z.im.Zero()
+}
+
+func (z cplx) One() bool {
+	re, ok := z.real()
+	return ok && re.One()
+}
+
+func (z cplx) MinusOne() bool {
+	re, ok := z.real()
+	return ok && re.MinusOne()
+}
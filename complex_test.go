@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cpx parses a complex value, like Value.String returns it.
+func cpx(s string) cplx {
+	z, err := newCplxFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return z
+}
+
+func TestCplxOps(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []testCase{
+		{"1 + 2i", OpAdd, "3 - i", "4 + i"},
+		{"1 + 2i", OpSub, "1 + 2i", "0"},
+		{"i", OpMul, "i", "-1"},
+		{"1 + 2i", OpMul, "3 - i", "5 + 5i"},
+		{"1", OpDiv, "1 + i", "1/2 - 1/2i"},
+		{"-4", OpPow, "1/2", "2i"},
+		{"-4", OpPow, "3/2", "-8i"},
+		{"-8", OpPow, "1/3", "-2"},
+		{"1 + i", OpPow, "2", "2i"},
+		{"i", OpPow, "-1", "-i"},
+		{"2", OpRoot, "-9", "3i"},
+		{"2i", OpPow, "1/2", "1 + i"},
+	} {
+		v, err := cpx(tc.a).PerformBinary(tc.op, cpx(tc.b))
+		assert.NoError(err)
+		assert.Equal(cpx(tc.r), v, "%s %s %s", tc.a, tc.op, tc.b)
+	}
+	for _, tc := range []struct {
+		a  string
+		op Op
+		r  string
+	}{
+		{"-4", OpSqrt, "2i"},
+		{"3 + 4i", OpSqrt, "2 + i"},
+		{"3 - 4i", OpSqrt, "2 - i"},
+		{"3 + 4i", OpAbs, "5"},
+		{"2i", OpRecip, "-1/2i"},
+		{"1 - i", OpMinus, "-1 + i"},
+		{"3", OpFact, "6"},
+	} {
+		v, err := cpx(tc.a).PerformUnary(tc.op)
+		assert.NoError(err)
+		assert.Equal(cpx(tc.r), v, "%s %s", tc.op, tc.a)
+	}
+	_, err := cpx("-2").PerformUnary(OpSqrt)
+	assert.True(errors.Is(err, ErrNotRational))
+	_, err = cpx("i").PerformUnary(OpFact)
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = cpx("2").PerformBinary(OpPow, cpx("i"))
+	assert.True(errors.Is(err, ErrNotRational))
+	_, err = cpx("i").PerformBinary(OpDiv, cpx("0"))
+	assert.True(errors.Is(err, ErrDivByZero))
+	_, err = cpx("1 + i").PerformUnary(OpAbs)
+	assert.True(errors.Is(err, ErrNotRational))
+}
+
+func TestCplxMisc(t *testing.T) {
+	assert := assert.New(t)
+	for _, s := range []string{"3", "-3/4", "i", "-i", "2i", "1/2 - 3/4i", "-1 + i"} {
+		assert.Equal(s, cpx(s).String())
+	}
+	assert.True(cpx("1").Less(cpx("2")))
+	assert.False(cpx("i").Less(cpx("2")))
+	assert.False(cpx("1").Less(cpx("2i")))
+	assert.False(cpx("1 - i").Less(cpx("1 + i")))
+
+	var p SolutionSlice
+	for _, s := range []string{"5", "2i", "3", "1 + i", "-1", "-i", "4", "1 - i", "2"} {
+		p = append(p, Solution{val: cpx(s)})
+	}
+	p.Sort()
+	var sorted []string
+	for _, s := range p {
+		sorted = append(sorted, s.val.String())
+	}
+	assert.Equal([]string{"-1", "2", "3", "4", "5", "-i", "1 + i", "1 - i", "2i"}, sorted)
+	assert.True(cpx("-2").Negative())
+	assert.False(cpx("-2i").Negative())
+	assert.True(cpx("4").Equal(rat("4")))
+	assert.False(inRange(cpx("4i"), 1, 0))
+	assert.True(inRange(cpx("4"), 1, 10))
+}
+
+func TestSearchComplex(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 3
+	complexValues = true
+	defer func() { complexValues = false }()
+	p, _ := Search("42")
+	found := make(map[string]bool)
+	for _, n := range p.Formulas(rat("-4")) {
+		found[n.String()] = true
+		v, err := n.Eval()
+		assert.NoError(err)
+		assert.True(v.Equal(rat("-4")))
+	}
+	assert.True(found["sqrt(-4) ^ 2"])
+
+	s := Solution{val: cpx("1/2 - 3i"), start: 0, end: 2}
+	s1, err := newCacheEntry(s).solution()
+	assert.NoError(err)
+	assert.Equal(s, s1)
+}
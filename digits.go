@@ -1,16 +1,126 @@
 package main
 
-import "os"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// commands maps sub-command names to their implementations. Anything else on the
+// command line is treated as a search: [flags] digits min max maxDepth.
+var commands = map[string]func(args []string) error{
+	"eval":      evalCmd,
+	"parse":     parseCmd,
+	"dot":       dotCmd,
+	"batch":     batchCmd,
+	"ticket":    ticketCmd,
+	"equations": equationsCmd,
+	"approx":    approxCmd,
+}
 
 func main() {
-	digits := os.Args[1]
-	min := atoi(os.Args[2])
-	max := atoi(os.Args[3])
-	maxDepth = atoi(os.Args[4])
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	showStats := flag.Bool("stats", false, "print search statistics to stderr")
+	cacheDir := flag.String("cache", "", "directory to keep search results in between runs")
+	checkpoint := flag.String("checkpoint", "", "file to periodically save the search progress to")
+	every := flag.Duration("checkpoint-every", time.Minute, "how often to save the search progress")
+	budget := flag.Duration("budget", 0, "if positive, stop the search after this time, not counting loading the checkpoint, and save a checkpoint")
+	resume := flag.Bool("resume", false, "continue the search from the checkpoint")
+	flag.BoolVar(&decimals, "decimals", false, "also use digits as decimals, like .5 or 1.(3)")
+	flag.BoolVar(&surds, "surds", false, "keep irrational square roots like sqrt(2) in intermediate values")
+	flag.BoolVar(&complexValues, "complex", false, "keep square roots of negatives like sqrt(-4) in intermediate values")
+	flag.BoolVar(&latexOutput, "latex", false, `print formulas in LaTeX, like \binom{5}{2} for C(5, 2)`)
+	flag.Int64Var(&modulus, "mod", 0, "if positive, calculate everything modulo this number; factorials, exponents "+
+		"and other operators on integers use the least nonnegative residues")
+	base := flag.Int("base", 10, "base of digits, min, max and numbers in formulas, from 2 to 36")
+	ops := flag.String("ops", "", "comma-separated optional operators to use, e.g. !!,subfact,#")
+	flag.Parse()
+	if flag.NArg() != 4 {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] digits min max maxDepth\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "digits are either a digit string like 123, or numbers like '25 50 7' or 25,50,7")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if err := setBase(*base); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// Digits above 9 are lowercase in formulas
+	digits := strings.ToLower(flag.Arg(0))
+	tokens, separated := splitTokens(digits)
+	if err := checkNumbers(tokens, separated); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	} else if separated {
+		// The same numbers share the cache and checkpoints however they are separated
+		digits = strings.Join(tokens, ",")
+	}
+	min := atoiBase(flag.Arg(1))
+	max := atoiBase(flag.Arg(2))
+	maxDepth = atoi(flag.Arg(3))
+	if surds && complexValues || modulus > 0 && (surds || complexValues) {
+		fmt.Fprintln(os.Stderr, "only one of --surds, --complex and --mod can be used")
+		os.Exit(2)
+	} else if modulus == 1 || modulus < 0 {
+		fmt.Fprintln(os.Stderr, "--mod should be at least 2")
+		os.Exit(2)
+	}
+	if *ops != "" {
+		if err := enableOps(*ops); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 	var p SolutionSlice
-	for _, s := range FindAllSolutions(digits, 0) {
-		p = append(p, s.AllUnary()...)
+	var st *Stats
+	var err error
+	if *checkpoint != "" {
+		c := &Checkpointer{Path: *checkpoint, Digits: digits, Interval: *every, Budget: *budget}
+		stopOnInterrupt(c)
+		if p, st, err = SearchResumable(c, *resume); err == ErrStopped {
+			fmt.Fprintf(os.Stderr, "%s, progress saved to %s; use --resume to continue\n", err, *checkpoint)
+			os.Exit(3)
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	} else if *resume {
+		fmt.Fprintln(os.Stderr, "--resume requires --checkpoint")
+		os.Exit(2)
+	} else if *cacheDir != "" {
+		if p, st, err = SearchCached(*cacheDir, digits); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot save cache: %s\n", err)
+		}
+	} else {
+		p, st = Search(digits)
 	}
-	p = uniq(p)
 	p.Print(maxDepth > 0, min, max)
+	if *showStats {
+		st.Print(os.Stderr)
+	}
+}
+
+// stopOnInterrupt stops the search on the first interrupt, so that a checkpoint is saved.
+// The second interrupt kills the program as usual.
+func stopOnInterrupt(c *Checkpointer) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ch
+		signal.Stop(ch)
+		fmt.Fprintln(os.Stderr, "interrupted, saving progress...")
+		c.Stop()
+	}()
 }
@@ -21,4 +21,73 @@ func TestNodeString(t *testing.T) {
 		},
 	}
 	assert.Equal("-(sqrt(9)!)", a.String())
+	for _, tc := range []struct{ polish, infix string }{
+		{"! ! 3", "(3!)!"},
+		{"!! 3", "3!!"},
+		{"!! + 1 2", "(1 + 2)!!"},
+		{"! !! 3", "(3!!)!"},
+		{"!! ! 3", "(3!)!!"},
+		{"# ! 3", "3!#"},
+		{"subfact 4", "!4"},
+		{"subfact ! 3", "!(3!)"},
+		{"! subfact 3", "(!3)!"},
+		{"-- # 5", "-(5#)"},
+		{"floor / 7 2", "⌊7 / 2⌋"},
+		{"ceil sqrt 5", "⌈sqrt(5)⌉"},
+		{"! round / 7 2", "round(7 / 2)!"},
+		{"recip 4", "1/(4)"},
+		{"recip ! 4", "1/(4!)"},
+		{"recip sqrt 4", "1/(sqrt(4))"},
+		{"^ 2 recip 4", "2 ^ (1/(4))"},
+		{"-- recip 4", "-(1/(4))"},
+		{"log recip 2 8", "log_(1/(2))(8)"},
+		{"log 1/2 8", "log_(1/2)(8)"},
+		{"abs - 1 5", "|1 - 5|"},
+		{"div 7 2", "7 div 2"},
+		{"mod + 7 1 * 2 3", "(7 + 1) mod (2 * 3)"},
+		{"div 9 mod 7 4", "9 div (7 mod 4)"},
+		{"C 5 2", "C(5, 2)"},
+		{"* 2 P + 3 4 2", "2 * P(3 + 4, 2)"},
+		{"^ root 3 8 2", "root(3, 8) ^ 2"},
+		{"log 2 8", "log_2(8)"},
+		{"log sqrt 4 + 4 4", "log_(sqrt(4))(4 + 4)"},
+		{"log 1/2 8", "log_(1/2)(8)"},
+	} {
+		n, err := FromPolish(tc.polish)
+		assert.NoError(err)
+		assert.Equal(tc.infix, n.String())
+	}
+}
+
+func TestNodeLaTeX(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []struct{ polish, latex string }{
+		{"C 5 2", `\binom{5}{2}`},
+		{"C 5 + 1 1", `\binom{5}{1 + 1}`},
+		{"* 2 C ! 3 C 2 1", `2 \cdot \binom{3!}{\binom{2}{1}}`},
+		{"P 5 C 2 1", `P(5, \binom{2}{1})`},
+		{"log 2 C 4 1", `\log_{2}(\binom{4}{1})`},
+		{"root 3 8", `\sqrt[3]{8}`},
+		{"root 3 C 4 2", `\sqrt[3]{\binom{4}{2}}`},
+		{"^ root + 1 2 8 2", `\sqrt[1 + 2]{8}^{2}`},
+		{"+ sqrt 4 / 1 2", `\sqrt{4} + \frac{1}{2}`},
+		{"- 1 + 2 3", "1 - (2 + 3)"},
+		{"* + 1 2 -3", `(1 + 2) \cdot (-3)`},
+		{"^ / 1 2 + 1 2", `(\frac{1}{2})^{1 + 2}`},
+		{"^ -- 2 2", "(-2)^{2}"},
+		{"- 3 1/2", `3 - \frac{1}{2}`},
+		{"-- -3", "-(-3)"},
+		{"! / 6 2", `(\frac{6}{2})!`},
+		{"-- ! 3", "-(3!)"},
+		{"# 5", `5\#`},
+		{"abs - 1 5", `\left|1 - 5\right|`},
+		{"floor recip 2", `\lfloor \frac{1}{2} \rfloor`},
+		{"mod 7 div 9 2", `7 \bmod (9 \operatorname{div} 2)`},
+		{"+ .(3) 1.2(3)", `.\overline{3} + 1.2\overline{3}`},
+	} {
+		n, err := FromPolish(tc.polish)
+		if assert.NoError(err, tc.polish) {
+			assert.Equal(tc.latex, n.LaTeX(), tc.polish)
+		}
+	}
 }
@@ -0,0 +1,62 @@
+// This file contains code for exporting formulas as Graphviz DOT graphs.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteDot writes formulas to w as a single Graphviz digraph, one cluster per formula.
+// Every operator and leaf becomes a graph node labeled with its opNames entry or value.
+// If values is true, operator nodes are also annotated with their intermediate values,
+// and the sub-node where evaluation failed (if any) is highlighted with the error.
+func WriteDot(w io.Writer, formulas []*Node, values bool) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "digraph formulas {\n\tordering=out;\n\tnode [shape=box];\n")
+	for i, n := range formulas {
+		fmt.Fprintf(b, "\tsubgraph cluster_%d {\n\t\tlabel=%q;\n", i, n.String())
+		var vals map[*Node]Value
+		var failed *StepError
+		if values {
+			vals = make(map[*Node]Value)
+			steps, err := n.Trace()
+			for _, s := range steps {
+				vals[s.Node] = s.Val
+			}
+			failed, _ = err.(*StepError)
+		}
+		id := 0
+		n.writeDot(b, fmt.Sprintf("n%d_", i), &id, vals, failed)
+		fmt.Fprintf(b, "\t}\n")
+	}
+	fmt.Fprintf(b, "}\n")
+	return b.Flush()
+}
+
+// writeDot writes n and its sub-nodes with ids prefix0, prefix1, ..., and returns the id of n.
+func (n *Node) writeDot(w io.Writer, prefix string, id *int, vals map[*Node]Value, failed *StepError) string {
+	name := fmt.Sprintf("%s%d", prefix, *id)
+	*id++
+	var label, attrs string
+	if n.op == OpNull {
+		label = n.literal()
+		attrs = ", shape=ellipse"
+	} else {
+		label = n.op.String()
+		if v, ok := vals[n]; ok {
+			label += "\n= " + v.String()
+		}
+	}
+	if failed != nil && failed.Node == n {
+		label += "\n" + failed.Err.Error()
+		attrs += ", color=red"
+	}
+	fmt.Fprintf(w, "\t\t%s [label=%q%s];\n", name, label, attrs)
+	for _, c := range []*Node{n.left, n.right} {
+		if c != nil {
+			fmt.Fprintf(w, "\t\t%s -> %s;\n", name, c.writeDot(w, prefix, id, vals, failed))
+		}
+	}
+	return name
+}
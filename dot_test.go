@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDot(t *testing.T) {
+	assert := assert.New(t)
+	n, err := FromPolish("* + 1 2 ! 3")
+	assert.NoError(err)
+	var b bytes.Buffer
+	assert.NoError(WriteDot(&b, []*Node{n}, true))
+	s := b.String()
+	assert.Contains(s, `label="(1 + 2) * 3!"`)
+	assert.Contains(s, `n0_0 [label="*\n= 18"]`)
+	assert.Contains(s, `n0_1 [label="+\n= 3"]`)
+	assert.Contains(s, `n0_2 [label="1", shape=ellipse]`)
+	assert.Contains(s, "n0_0 -> n0_1;")
+	assert.Contains(s, "n0_0 -> n0_4;")
+
+	n, err = FromPolish("/ 1 0")
+	assert.NoError(err)
+	b.Reset()
+	assert.NoError(WriteDot(&b, []*Node{n}, true))
+	assert.Contains(b.String(), "color=red")
+}
@@ -0,0 +1,158 @@
+// This file contains structured serializations of nodes: JSON and S-expressions.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// opByName returns an operator by its name in opNames.
+func opByName(name string) (Op, bool) {
+	for op, s := range opNames {
+		if s == name && op != OpNull {
+			return op, true
+		}
+	}
+	return OpNull, false
+}
+
+// jsonNode is the JSON schema of a Node. Leafs are encoded as {"val": "3/4"}, and
+// other nodes as {"op": "+", "args": [...]}, with op being the operator name used
+// in Polish notation (unary minus is "--"), and one or two args.
+type jsonNode struct {
+	Op   string  `json:"op,omitempty"`
+	Val  string  `json:"val,omitempty"`
+	Args []*Node `json:"args,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	if !n.valid() {
+		return nil, fmt.Errorf("%w %s", ErrInvalidNode, n)
+	}
+	if n.op == OpNull {
+		return json.Marshal(jsonNode{Val: n.literal()})
+	}
+	j := jsonNode{Op: n.op.String(), Args: []*Node{n.left}}
+	if n.right != nil {
+		j.Args = append(j.Args, n.right)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Every decoded node is checked with Node.valid().
+func (n *Node) UnmarshalJSON(b []byte) error {
+	var j jsonNode
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	var n1 *Node
+	if j.Op == "" {
+		if len(j.Args) != 0 {
+			return fmt.Errorf("%w: leaf with arguments", ErrInvalidNode)
+		}
+		var err error
+		if n1, err = newLitNode(j.Val); err != nil {
+			return err
+		}
+	} else {
+		op, ok := opByName(j.Op)
+		if !ok {
+			return fmt.Errorf("%w: unknown operator '%s'", ErrInvalidNode, j.Op)
+		}
+		n1 = &Node{op: op}
+		if len(j.Args) > 0 {
+			n1.left = j.Args[0]
+		}
+		if len(j.Args) > 1 {
+			n1.right = j.Args[1]
+		}
+		if len(j.Args) > 2 || j.Val != "" || !n1.valid() {
+			return fmt.Errorf("%w: wrong arguments for '%s'", ErrInvalidNode, j.Op)
+		}
+	}
+	*n = *n1
+	return nil
+}
+
+// ToSExpr returns n as an S-expression, e.g. (* (+ 1 2) (! 3)). Operators are named as
+// in Polish notation, so unary minus is (-- 3).
+func (n *Node) ToSExpr() string {
+	if !n.valid() {
+		return fmt.Sprintf("invalid formula: '%s'", n)
+	}
+	if n.op == OpNull {
+		return n.literal()
+	}
+	s := "(" + n.op.String() + " " + n.left.ToSExpr()
+	if n.right != nil {
+		s += " " + n.right.ToSExpr()
+	}
+	return s + ")"
+}
+
+// FromSExpr parses a node from an S-expression written by ToSExpr. Since the number of
+// arguments is explicit, unary minus can be written both as (-- 3) and (- 3).
+func FromSExpr(s string) (*Node, error) {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s))
+	n, rest, err := parseSExpr(tokens)
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("unexpected '%s' after the formula", strings.Join(rest, " "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse '%s': %s", s, err)
+	}
+	return n, nil
+}
+
+// parseSExpr parses a node from the beginning of tokens, and returns the remaining tokens.
+func parseSExpr(tokens []string) (*Node, []string, error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end of input")
+	}
+	t := tokens[0]
+	if t == ")" {
+		return nil, nil, fmt.Errorf("unexpected ')'")
+	} else if t != "(" {
+		n, err := newLitNode(t)
+		if err != nil {
+			return nil, nil, err
+		}
+		return n, tokens[1:], nil
+	}
+	if len(tokens) < 2 {
+		return nil, nil, fmt.Errorf("operator missing")
+	}
+	op, ok := opByName(tokens[1])
+	if !ok {
+		return nil, nil, fmt.Errorf("unrecognized operator '%s'", tokens[1])
+	}
+	var args []*Node
+	tokens = tokens[2:]
+	for len(tokens) > 0 && tokens[0] != ")" {
+		var a *Node
+		var err error
+		if a, tokens, err = parseSExpr(tokens); err != nil {
+			return nil, nil, err
+		}
+		args = append(args, a)
+	}
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("missing ')'")
+	}
+	if op == OpSub && len(args) == 1 {
+		op = OpMinus
+	}
+	n := &Node{op: op}
+	if len(args) > 0 {
+		n.left = args[0]
+	}
+	if len(args) > 1 {
+		n.right = args[1]
+	}
+	if len(args) > 2 || !n.valid() {
+		return nil, nil, fmt.Errorf("wrong number of arguments for '%s'", op)
+	}
+	return n, tokens[1:], nil
+}
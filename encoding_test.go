@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeJSON(t *testing.T) {
+	assert := assert.New(t)
+	n, err := FromPolish("* + 1/2 2 -- ! 3")
+	assert.NoError(err)
+	b, err := json.Marshal(n)
+	assert.NoError(err)
+	assert.Equal(`{"op":"*","args":[{"op":"+","args":[{"val":"1/2"},{"val":"2"}]},{"op":"--","args":[{"op":"!","args":[{"val":"3"}]}]}]}`, string(b))
+	var n1 Node
+	assert.NoError(json.Unmarshal(b, &n1))
+	assert.True(n.Equal(&n1))
+
+	for _, s := range []string{
+		`{"op":"+","args":[{"val":"1"}]}`,
+		`{"op":"!","args":[{"val":"1"},{"val":"2"}]}`,
+		`{"op":"?","args":[{"val":"1"}]}`,
+		`{"val":"1","args":[{"val":"2"}]}`,
+	} {
+		err := json.Unmarshal([]byte(s), &n1)
+		assert.True(errors.Is(err, ErrInvalidNode), "decoding %s", s)
+	}
+	assert.Error(json.Unmarshal([]byte(`{"val":"1/0"}`), &n1))
+}
+
+func TestNodeSExpr(t *testing.T) {
+	assert := assert.New(t)
+	n, err := FromPolish("* + 1 2 ! 3")
+	assert.NoError(err)
+	assert.Equal("(* (+ 1 2) (! 3))", n.ToSExpr())
+	for _, p := range []string{"1", "-3/4", "-- 1", "/ sqrt 2 ^ ! 3/4 -- -5/6", "- - 1 2 - 3 4"} {
+		node, err := FromPolish(p)
+		assert.NoError(err)
+		n1, err := FromSExpr(node.ToSExpr())
+		if assert.NoError(err, "parsing %s", node.ToSExpr()) {
+			assert.True(n1.Equal(node), "parsing %s", node.ToSExpr())
+		}
+	}
+	n1, err := FromSExpr("(sqrt (- -1/2))")
+	assert.NoError(err)
+	assert.Equal("sqrt -- -1/2", n1.ToPolish())
+	for _, s := range []string{"", "(", "()", "(+ 1)", "(+ 1 2 3)", "(! 1 2)", "(+ 1 2))", "(x 1)", "1 2"} {
+		_, err := FromSExpr(s)
+		assert.Error(err, "parsing '%s'", s)
+	}
+}
@@ -0,0 +1,146 @@
+// This file contains search for equations, where digits are split by an equals sign.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Equation is a pair of formulas with the same value Val, made of tokens[:Split]
+// and tokens[Split:] of the original digits string (see splitTokens).
+type Equation struct {
+	Left, Right *Node
+	Val         Value
+	Split       int
+}
+
+func (e Equation) String() string {
+	return fmt.Sprintf("%s = %s", e.Left, e.Right)
+}
+
+// shortest returns the formula for s with the smallest depth, or nil if there are none.
+func (s Solution) shortest() *Node {
+	var best *Node
+	for _, n := range solutions[s] {
+		if best == nil || n.Depth() < best.Depth() || n.Depth() == best.Depth() && n.String() < best.String() {
+			best = n
+		}
+	}
+	return best
+}
+
+// FindTicket finds all values which can be made both of the tokens of digits before split
+// and of the ones after it, and returns an equation with the shortest formulas for each
+// of them. Like FindAllSolutions, it reuses solutions found so far for digits.
+func FindTicket(digits string, split int) []Equation {
+	tokens, separated := splitTokens(digits)
+	return findEquations(tokens, separated, split, func(s Solution) []*Node {
+		if n := s.shortest(); n != nil {
+			return []*Node{n}
+		}
+		return nil
+	})
+}
+
+// findEquations returns equations for all values which can be made both of tokens[:split]
+// and of tokens[split:], combining every formula returned by formulas for the left side
+// with every one for the right side.
+func findEquations(tokens []string, separated bool, split int, formulas func(Solution) []*Node) []Equation {
+	if split <= 0 || split >= len(tokens) {
+		return nil
+	}
+	left := findAllSolutions(tokens[:split], separated, 0).AllUnary()
+	right := findAllSolutions(tokens[split:], separated, split).AllUnary()
+	values := make(map[Value][]*Node)
+	for _, s := range right {
+		values[s.val] = formulas(s)
+	}
+	var result []Equation
+	left.Sort()
+	for _, s := range left {
+		for _, l := range formulas(s) {
+			for _, r := range values[s.val] {
+				result = append(result, Equation{Left: l, Right: r, Val: s.val, Split: split})
+			}
+		}
+	}
+	return result
+}
+
+// FindTickets returns equations from FindTicket for every split point of digits,
+// or only for the middle one if half is true, simplest equations first for every
+// split point. It returns nil if there are none, and an error if digits are invalid.
+func FindTickets(digits string, half bool) ([]Equation, error) {
+	tokens, separated := splitTokens(digits)
+	if !isDigits(strings.Join(tokens, "")) {
+		return nil, fmt.Errorf("invalid digits '%s'", digits)
+	} else if err := checkNumbers(tokens, separated); err != nil {
+		return nil, err
+	}
+	resetSolutions()
+	var result []Equation
+	for split := 1; split < len(tokens); split++ {
+		if half && split != len(tokens)/2 {
+			continue
+		}
+		result = append(result, FindTicket(digits, split)...)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		ei, ej := result[i], result[j]
+		return ei.Split < ej.Split || ei.Split == ej.Split && ei.depth() < ej.depth()
+	})
+	return result, nil
+}
+
+// FindEquations finds all equations made of digits. If digits contain "=", like 123=45
+// or 25,50=75, they are split there, otherwise every position for "=" between the tokens
+// (see splitTokens) is tried. Equations are deduplicated by the canonical form (see
+// Node.Simplify) of both sides.
+func FindEquations(digits string) ([]Equation, error) {
+	sides := strings.Split(digits, "=")
+	if len(sides) > 2 {
+		return nil, fmt.Errorf("more than one '=' in %s", digits)
+	}
+	// Separators on either side separate all numbers, and "=" is one of them
+	tokens, separated := splitTokens(strings.Join(sides, ""))
+	if separated {
+		tokens, _ = splitTokens(strings.Join(sides, ","))
+	}
+	fixed := -1
+	if len(sides) == 2 {
+		fixed = len(sides[0])
+		if separated {
+			fixed = len(strings.FieldsFunc(sides[0], isSeparator))
+		}
+	}
+	if fixed == 0 || fixed == len(tokens) {
+		return nil, fmt.Errorf("no digits on one side of '='")
+	} else if !isDigits(strings.Join(tokens, "")) {
+		return nil, fmt.Errorf("invalid digits '%s'", digits)
+	} else if err := checkNumbers(tokens, separated); err != nil {
+		return nil, err
+	}
+	resetSolutions()
+	seen := make(map[string]bool)
+	var result []Equation
+	for split := 1; split < len(tokens); split++ {
+		if fixed >= 0 && split != fixed {
+			continue
+		}
+		for _, e := range findEquations(tokens, separated, split, func(s Solution) []*Node { return solutions[s] }) {
+			e.Left, e.Right = e.Left.Simplify(), e.Right.Simplify()
+			key := e.Left.ToPolish() + " = " + e.Right.ToPolish()
+			if !seen[key] {
+				seen[key] = true
+				result = append(result, e)
+			}
+		}
+	}
+	return result, nil
+}
+
+// depth returns the total depth of both sides of e.
+func (e Equation) depth() int64 {
+	return e.Left.Depth() + e.Right.Depth()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindTickets(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 0
+	eqs, err := FindTickets("123321", true)
+	assert.NoError(err)
+	assert.NotEmpty(eqs)
+	for _, e := range eqs {
+		assert.Equal(3, e.Split)
+		l, err := e.Left.Eval()
+		assert.NoError(err)
+		r, err := e.Right.Eval()
+		assert.NoError(err)
+		assert.True(l.Equal(r), "%s", e)
+		assert.True(l.Equal(e.Val), "%s", e)
+	}
+	assert.Equal(int64(2), eqs[0].depth())
+
+	all, err := FindTickets("1234", false)
+	assert.NoError(err)
+	splits := map[int]bool{}
+	for _, e := range all {
+		splits[e.Split] = true
+	}
+	assert.Equal(map[int]bool{1: true, 2: true, 3: true}, splits)
+
+	eqs, err = FindTickets("7", false)
+	assert.NoError(err)
+	assert.Empty(eqs)
+
+	eqs, err = FindTickets("36,6", false)
+	assert.NoError(err)
+	assert.NotEmpty(eqs)
+	for _, e := range eqs {
+		assert.Equal(1, e.Split)
+		assert.Contains(e.Left.String(), "36", "%s", e)
+	}
+
+	for _, digits := range []string{"", ",", "12a4", "1.5,2", "12-3", "12345678901234567890", "99999999999999999999,1"} {
+		_, err = FindTickets(digits, false)
+		assert.Error(err, digits)
+	}
+}
+
+func TestFindEquations(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 0
+	eqs, err := FindEquations("12=3")
+	assert.NoError(err)
+	seen := map[string]bool{}
+	for _, e := range eqs {
+		assert.Equal(2, e.Split)
+		s := e.Left.ToPolish() + " = " + e.Right.ToPolish()
+		assert.False(seen[s], "duplicate %s", e)
+		seen[s] = true
+	}
+	assert.True(seen["+ 1 2 = 3"])
+
+	eqs, err = FindEquations("123")
+	assert.NoError(err)
+	splits := map[int]bool{}
+	for _, e := range eqs {
+		splits[e.Split] = true
+	}
+	assert.Equal(map[int]bool{1: true, 2: true}, splits)
+
+	for _, digits := range []string{"36,6", "36=6", "1,2,3=4", "12=3,4"} {
+		eqs, err = FindEquations(digits)
+		assert.NoError(err, "%s", digits)
+		for _, e := range eqs {
+			l, err := e.Left.Eval()
+			assert.NoError(err)
+			r, err := e.Right.Eval()
+			assert.NoError(err)
+			assert.True(l.Equal(r), "%s", e)
+		}
+	}
+	eqs, err = FindEquations("36,6")
+	assert.NoError(err)
+	for _, e := range eqs {
+		assert.Equal(1, e.Split, "%s", e)
+		assert.NotEqual("3! = 6", e.String())
+	}
+	eqs, err = FindEquations("12=3,4")
+	assert.NoError(err)
+	if assert.NotEmpty(eqs) {
+		assert.Equal(1, eqs[0].Split, "12 is a single number")
+	}
+
+	for _, s := range []string{"1=2=3", "=12", "12=", "1a=2", "1,2=", "99999999999999999999=1"} {
+		_, err := FindEquations(s)
+		assert.Error(err, "%s", s)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors describing why a value could not be calculated. Errors returned by
+// Value implementations wrap one of them, so use errors.Is to check the reason.
+var (
+	ErrDivByZero   = errors.New("division by zero")
+	ErrOverflow    = errors.New("overflow")
+	ErrNotRational = errors.New("result is not rational")
+	ErrDomain      = errors.New("argument out of domain")
+	ErrInvalidNode = errors.New("invalid formula")
+	ErrNotSurd     = errors.New("result is not a quadratic surd")
+	ErrMismatch    = errors.New("mismatched value types")
+)
+
+// OpError is returned when Op cannot be performed on Args (one argument for unary
+// operators and two for binary ones). Err is one of the sentinel errors above.
+type OpError struct {
+	Op   Op
+	Args []Value
+	Err  error
+}
+
+// newOpError creates an *OpError for op applied to args, failed because of err.
+func newOpError(err error, op Op, args ...Value) *OpError {
+	return &OpError{Op: op, Args: args, Err: err}
+}
+
+func (e *OpError) Error() string {
+	n := &Node{op: e.Op}
+	if len(e.Args) > 0 {
+		n.left = newValNode(e.Args[0])
+	}
+	if len(e.Args) > 1 {
+		n.right = newValNode(e.Args[1])
+	}
+	return fmt.Sprintf("cannot calculate %s: %s", n, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
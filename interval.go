@@ -0,0 +1,311 @@
+// This file contains float intervals with guaranteed bounds, used to find approximations.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// interval stores a real value somewhere in [lo, hi]. Values which are known exactly, like
+// numbers written with digits, also store the exact rational r. Operations are performed
+// on r while the result is rational, and on the bounds otherwise.
+//
+// Bounds of inexact values are rounded outwards to intervalBits bits of mantissa, so that
+// values calculated in different ways usually get the same bounds. Equal and Less compare
+// exact values by their rationals, and other values by their bounds: Equal means the
+// same bounds, and Less orders intervals by lo, then by hi. Neither of them says anything
+// about the real values inside overlapping intervals.
+type interval struct {
+	lo, hi float64
+	exact  bool
+	r      rational
+}
+
+// intervalBits is the precision of bounds of inexact intervals.
+const intervalBits = 40
+
+// maxExactFloat is the largest integer n such that all integers up to n are float64.
+const maxExactFloat = 1 << 53
+
+// exactInterval creates an interval for r.
+func exactInterval(r rational) interval {
+	r = r.normalize()
+	f := r.Value()
+	if r.d == 1 && r.n <= maxExactFloat && r.n >= -maxExactFloat {
+		return interval{lo: f, hi: f, exact: true, r: r}
+	}
+	// Both the conversion of n and d and the division may be rounded
+	i, _ := newInterval(f, f, roundingError, OpNull)
+	i.exact, i.r = true, r
+	return i
+}
+
+// newInterval creates an inexact interval with bounds at least as wide as [lo, hi]
+// widened by rel * |bound| for results of functions with inexact results, or an error
+// if they are not finite.
+func newInterval(lo, hi, rel float64, op Op, args ...Value) (interval, error) {
+	if math.IsNaN(lo) || math.IsNaN(hi) || math.IsInf(lo, 0) || math.IsInf(hi, 0) {
+		return interval{}, newOpError(ErrOverflow, op, args...)
+	}
+	lo = math.Nextafter(lo-math.Abs(lo)*rel, math.Inf(-1))
+	hi = math.Nextafter(hi+math.Abs(hi)*rel, math.Inf(1))
+	return interval{lo: roundBound(lo, math.Floor), hi: roundBound(hi, math.Ceil)}, nil
+}
+
+// roundBound rounds x to intervalBits bits of mantissa using round, which is math.Floor
+// or math.Ceil.
+func roundBound(x float64, round func(float64) float64) float64 {
+	frac, exp := math.Frexp(x)
+	return math.Ldexp(round(math.Ldexp(frac, intervalBits)), exp-intervalBits)
+}
+
+// toInterval converts intervals and rationals to interval.
+func toInterval(v Value) (interval, bool) {
+	switch v := v.(type) {
+	case interval:
+		return v, true
+	case rational:
+		return exactInterval(v), true
+	}
+	return interval{}, false
+}
+
+// newIntervalFromString is an opposite of interval.String: it parses rationals and
+// inexact values like [1.5, 1.75].
+func newIntervalFromString(s string) (interval, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") {
+		r, err := newRationalFromString(s)
+		return exactInterval(r), err
+	}
+	p := strings.Split(strings.Trim(s, "[]"), ",")
+	if len(p) != 2 {
+		return interval{}, fmt.Errorf("cannot convert %s to interval", s)
+	}
+	lo, err := strconv.ParseFloat(strings.TrimSpace(p[0]), 64)
+	if err != nil {
+		return interval{}, err
+	}
+	hi, err := strconv.ParseFloat(strings.TrimSpace(p[1]), 64)
+	if err != nil {
+		return interval{}, err
+	}
+	return interval{lo: lo, hi: hi}, nil
+}
+
+func (i interval) String() string {
+	if i.exact {
+		return i.r.String()
+	}
+	return fmt.Sprintf("[%s, %s]", strconv.FormatFloat(i.lo, 'g', -1, 64), strconv.FormatFloat(i.hi, 'g', -1, 64))
+}
+
+// Mid returns the middle of i.
+func (i interval) Mid() float64 {
+	if i.exact {
+		return i.r.Value()
+	}
+	return i.lo/2 + i.hi/2
+}
+
+// rational returns i as rational, and false if it is not known exactly.
+func (i interval) rational() (rational, bool) {
+	return i.r, i.exact
+}
+
+// PerformUnary is an implementation of Value.PerformUnary. Operators on integers, like
+// factorial, are only defined for exact values.
+func (i interval) PerformUnary(op Op) (Value, error) {
+	if i.exact {
+		v, err := i.r.PerformUnary(op)
+		if err == nil {
+			return exactInterval(v.(rational)), nil
+		} else if !errors.Is(err, ErrNotRational) && !errors.Is(err, ErrOverflow) {
+			return interval{}, rewrap(err, op, i)
+		}
+	}
+	switch op {
+	case OpMinus:
+		return interval{lo: -i.hi, hi: -i.lo}, nil
+	case OpSqrt:
+		if i.lo < 0 {
+			return interval{}, newOpError(ErrDomain, op, i)
+		}
+		return newInterval(math.Sqrt(i.lo), math.Sqrt(i.hi), 0, op, i)
+	case OpRecip:
+		return intervalOne.div(i, op)
+	case OpAbs:
+		if i.lo >= 0 {
+			return i, nil
+		} else if i.hi <= 0 {
+			return interval{lo: -i.hi, hi: -i.lo}, nil
+		}
+		return interval{lo: 0, hi: math.Max(-i.lo, i.hi)}, nil
+	case OpFloor:
+		return i.round(op, math.Floor)
+	case OpCeil:
+		return i.round(op, math.Ceil)
+	case OpRound:
+		return i.round(op, math.Round)
+	}
+	return interval{}, newOpError(ErrDomain, op, i)
+}
+
+var intervalOne = exactInterval(rational{1, 1})
+
+// round applies op calculated by round, which succeeds only if both bounds are rounded
+// to the same integer.
+func (i interval) round(op Op, round func(float64) float64) (Value, error) {
+	if lo, hi := round(i.lo), round(i.hi); lo == hi && math.Abs(lo) <= maxExactFloat {
+		return exactInterval(rational{int64(lo), 1}), nil
+	}
+	return interval{}, newOpError(ErrDomain, op, i)
+}
+
+// PerformBinary is an implementation of Value.PerformBinary. Operators on integers,
+// like C, are only defined for exact values.
+func (i interval) PerformBinary(op Op, v Value) (Value, error) {
+	j, ok := toInterval(v)
+	if !ok {
+		return interval{}, newOpError(ErrMismatch, op, i, v)
+	}
+	if i.exact && j.exact {
+		v, err := i.r.PerformBinary(op, j.r)
+		if err == nil {
+			return exactInterval(v.(rational)), nil
+		} else if !errors.Is(err, ErrNotRational) && !errors.Is(err, ErrOverflow) {
+			return interval{}, rewrap(err, op, i, j)
+		}
+	}
+	switch op {
+	case OpAdd:
+		return newInterval(i.lo+j.lo, i.hi+j.hi, 0, op, i, j)
+	case OpSub:
+		return newInterval(i.lo-j.hi, i.hi-j.lo, 0, op, i, j)
+	case OpMul:
+		lo, hi := minMax(i.lo*j.lo, i.lo*j.hi, i.hi*j.lo, i.hi*j.hi)
+		return newInterval(lo, hi, 0, op, i, j)
+	case OpDiv:
+		return i.div(j, op)
+	case OpPow:
+		return i.pow(j, op)
+	case OpRoot:
+		e, err := intervalOne.div(i, op)
+		if err != nil {
+			return interval{}, err
+		}
+		return j.pow(e, op)
+	case OpLog:
+		if i.lo <= 0 || j.lo <= 0 || i.lo <= 1 && i.hi >= 1 {
+			return interval{}, newOpError(ErrDomain, op, i, j)
+		}
+		a, err := newInterval(math.Log(j.lo), math.Log(j.hi), transcendental, op, i, j)
+		if err != nil {
+			return interval{}, err
+		}
+		b, err := newInterval(math.Log(i.lo), math.Log(i.hi), transcendental, op, i, j)
+		if err != nil {
+			return interval{}, err
+		}
+		return a.div(b, op)
+	}
+	return interval{}, newOpError(ErrDomain, op, i, j)
+}
+
+// transcendental is the relative error of math functions like math.Pow, which are not
+// correctly rounded, and roundingError is enough for a few rounded operations.
+const (
+	transcendental = 1.0 / (1 << 50)
+	roundingError  = 1.0 / (1 << 51)
+)
+
+// div returns i / j, or an error of op if j may be zero.
+func (i interval) div(j interval, op Op) (interval, error) {
+	if j.lo <= 0 && j.hi >= 0 {
+		return interval{}, newOpError(ErrDivByZero, op, i, j)
+	}
+	lo, hi := minMax(i.lo/j.lo, i.lo/j.hi, i.hi/j.lo, i.hi/j.hi)
+	return newInterval(lo, hi, 0, op, i, j)
+}
+
+// pow returns i ^ j. Negative i can only be raised to exact integer powers.
+func (i interval) pow(j interval, op Op) (interval, error) {
+	if i.lo > 0 {
+		lo, hi := minMax(math.Pow(i.lo, j.lo), math.Pow(i.lo, j.hi), math.Pow(i.hi, j.lo), math.Pow(i.hi, j.hi))
+		return newInterval(lo, hi, transcendental, op, i, j)
+	}
+	n, ok := j.rational()
+	if !ok || !n.IsInteger() {
+		return interval{}, newOpError(ErrDomain, op, i, j)
+	} else if n.n < 0 {
+		// 1/i first, as i^-n may overflow when i^n doesn't
+		r, err := intervalOne.div(i, op)
+		if err != nil {
+			return interval{}, err
+		}
+		return r.pow(exactInterval(n.Minus()), op)
+	}
+	lo, hi := math.Pow(i.lo, float64(n.n)), math.Pow(i.hi, float64(n.n))
+	if n.n%2 == 0 {
+		// x^n decreases for negative x
+		lo, hi = minMax(lo, hi)
+		if i.hi >= 0 {
+			lo = 0
+		}
+	}
+	return newInterval(lo, hi, transcendental, op, i, j)
+}
+
+// minMax returns the smallest and the largest of x.
+func minMax(x ...float64) (float64, float64) {
+	lo, hi := x[0], x[0]
+	for _, f := range x[1:] {
+		lo, hi = math.Min(lo, f), math.Max(hi, f)
+	}
+	return lo, hi
+}
+
+// Equal is an implementation of Value.Equal, see interval.
+func (i interval) Equal(v Value) bool {
+	j, ok := toInterval(v)
+	return ok && i == j
+}
+
+// Less is an implementation of Value.Less, see interval.
+func (i interval) Less(v Value) bool {
+	j, ok := toInterval(v)
+	if !ok {
+		return false
+	} else if i.exact && j.exact {
+		return i.r.isLess(j.r)
+	}
+	return i.lo < j.lo || i.lo == j.lo && i.hi < j.hi
+}
+
+func (i interval) IsInteger() bool {
+	return i.exact && i.r.IsInteger()
+}
+
+// Negative returns true if the whole interval is negative.
+func (i interval) Negative() bool {
+	return i.hi < 0
+}
+
+func (i interval) Even() bool {
+	return i.exact && i.r.Even()
+}
+
+func (i interval) Zero() bool {
+	return i.exact && i.r.Zero()
+}
+
+func (i interval) One() bool {
+	return i.exact && i.r.One()
+}
+
+func (i interval) MinusOne() bool {
+	return i.exact && i.r.MinusOne()
+}
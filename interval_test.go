@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ival returns an exact interval for a rational written as s.
+func ival(s string) interval {
+	return exactInterval(rat(s))
+}
+
+func TestIntervalOps(t *testing.T) {
+	assert := assert.New(t)
+	sqrt2, err := ival("2").PerformUnary(OpSqrt)
+	assert.NoError(err)
+	for _, tc := range []struct {
+		v    Value
+		err  error
+		want float64
+	}{
+		{sqrt2, nil, math.Sqrt2},
+		{mustBinary(ival("2"), OpPow, ival("1/3")), nil, math.Cbrt(2)},
+		{mustBinary(sqrt2, OpMul, sqrt2), nil, 2},
+		{mustBinary(sqrt2, OpSub, ival("3")), nil, math.Sqrt2 - 3},
+		{mustBinary(ival("1"), OpDiv, sqrt2), nil, 1 / math.Sqrt2},
+		{mustBinary(ival("2"), OpLog, ival("3")), nil, math.Log2(3)},
+		{mustBinary(ival("3"), OpRoot, ival("10")), nil, math.Cbrt(10)},
+		{mustBinary(mustUnary(sqrt2, OpMinus), OpPow, ival("3")), nil, -2 * math.Sqrt2},
+		{mustBinary(ival("2"), OpPow, ival("100")), nil, math.Pow(2, 100)},
+	} {
+		i := tc.v.(interval)
+		assert.False(i.exact, "%s", i)
+		assert.True(i.lo <= tc.want && tc.want <= i.hi, "%s should contain %v", i, tc.want)
+		assert.InDelta(tc.want, i.Mid(), math.Abs(tc.want)*1e-11)
+	}
+
+	// x^-n is not calculated as 1/x^n, which may overflow
+	v, err := ival("-2").PerformBinary(OpPow, ival("-1030"))
+	if assert.NoError(err) {
+		i := v.(interval)
+		assert.True(i.lo <= math.Pow(2, -1030) && math.Pow(2, -1030) <= i.hi, "%s", i)
+	}
+
+	// Rational results stay exact
+	v, err = ival("9").PerformBinary(OpPow, ival("1/2"))
+	assert.NoError(err)
+	assert.Equal(ival("3"), v)
+	v, err = ival("1").PerformBinary(OpDiv, ival("3"))
+	assert.NoError(err)
+	assert.True(v.(interval).exact)
+	assert.True(v.Equal(rat("1/3")))
+	v, err = mustUnary(sqrt2, OpFloor).PerformUnary(OpFact)
+	assert.NoError(err)
+	assert.Equal(ival("1"), v)
+
+	_, err = sqrt2.PerformUnary(OpFact)
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = ival("-2").PerformUnary(OpSqrt)
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = ival("1").PerformBinary(OpDiv, mustBinary(sqrt2, OpSub, sqrt2))
+	assert.True(errors.Is(err, ErrDivByZero))
+	_, err = ival("10").PerformBinary(OpPow, mustBinary(ival("400"), OpMul, sqrt2))
+	assert.True(errors.Is(err, ErrOverflow))
+}
+
+func mustUnary(v Value, op Op) Value {
+	r, err := v.PerformUnary(op)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func mustBinary(v Value, op Op, v1 Value) Value {
+	r, err := v.PerformBinary(op, v1)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestIntervalMisc(t *testing.T) {
+	assert := assert.New(t)
+	sqrt2 := mustUnary(ival("2"), OpSqrt)
+	assert.True(sqrt2.Equal(mustUnary(ival("2"), OpSqrt)))
+	assert.False(sqrt2.Equal(mustUnary(ival("3"), OpSqrt)))
+	assert.False(sqrt2.Equal(ival("2")))
+	assert.True(ival("1").Less(sqrt2))
+	assert.True(sqrt2.Less(ival("3/2")))
+	assert.True(mustUnary(sqrt2, OpMinus).Negative())
+	assert.True(ival("4").IsInteger())
+	assert.False(mustBinary(sqrt2, OpMul, sqrt2).IsInteger())
+
+	for _, v := range []Value{ival("3"), ival("-1/3"), sqrt2} {
+		i, err := newIntervalFromString(v.String())
+		assert.NoError(err)
+		assert.Equal(v, i)
+	}
+}
@@ -1,13 +1,20 @@
 package main
 
-import "math"
+import (
+	"math"
+	"math/bits"
+)
 
 var factLookup, sqrtLookup map[int64]int64
+var doubleFactLookup, subfactLookup, primorialLookup map[int64]int64
 
 const (
-	maxFactorial = 20      // Pre-calculate n! up to this
-	maxSqrt      = 1000000 // Pre-calculate sqrt[n] up to this
-	maxSqrt2     = maxSqrt * maxSqrt
+	maxFactorial       = 20      // Pre-calculate n! up to this
+	maxDoubleFactorial = 33      // Pre-calculate n!! up to this
+	maxSubfactorial    = 20      // Pre-calculate !n up to this
+	maxPrimorial       = 52      // Pre-calculate n# up to this
+	maxSqrt            = 1000000 // Pre-calculate sqrt[n] up to this
+	maxSqrt2           = maxSqrt * maxSqrt
 )
 
 func init() {
@@ -24,6 +31,61 @@ func init() {
 	for i = 1; i <= maxSqrt; i++ {
 		sqrtLookup[i*i] = i
 	}
+
+	doubleFactLookup = map[int64]int64{0: 1, 1: 1}
+	for i = 2; i <= maxDoubleFactorial; i++ {
+		doubleFactLookup[i] = i * doubleFactLookup[i-2]
+	}
+	subfactLookup = map[int64]int64{0: 1, 1: 0}
+	for i = 2; i <= maxSubfactorial; i++ {
+		subfactLookup[i] = (i - 1) * (subfactLookup[i-1] + subfactLookup[i-2])
+	}
+	primorialLookup = map[int64]int64{0: 1}
+	for i = 1; i <= maxPrimorial; i++ {
+		primorialLookup[i] = primorialLookup[i-1]
+		if isPrime(i) {
+			primorialLookup[i] *= i
+		}
+	}
+}
+
+// isPrime returns true if n is a prime number.
+func isPrime(n int64) bool {
+	if n < 2 {
+		return false
+	}
+	for d := int64(2); d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// doubleFact calculates n!! using lookup table, and returns MaxInt64 for invalid inputs
+func doubleFact(n int64) int64 {
+	if r, ok := doubleFactLookup[n]; ok {
+		return r
+	}
+	return MaxInt64
+}
+
+// subfact calculates !n (the number of derangements) using lookup table, and returns MaxInt64
+// for invalid inputs
+func subfact(n int64) int64 {
+	if r, ok := subfactLookup[n]; ok {
+		return r
+	}
+	return MaxInt64
+}
+
+// primorial calculates n# (the product of primes up to n) using lookup table, and returns
+// MaxInt64 for invalid inputs
+func primorial(n int64) int64 {
+	if r, ok := primorialLookup[n]; ok {
+		return r
+	}
+	return MaxInt64
 }
 
 // fact calculates n! using lookup table, and returns MaxInt64 for invalid inputs
@@ -81,8 +143,7 @@ func root(a, b int64) int64 {
 
 const MaxInt64 = 9223372036854775807
 
-// pow returns a^b, if both of them are small enough, or MaxInt64 if the result is invalid
-// FIXME: Once we support ratios, we should support a^r where r is a ratio, too.
+// pow returns a^b for non-negative b, or MaxInt64 if the result is invalid or overflows.
 func pow(a, b int64) int64 {
 	if a == 0 && b <= 0 {
 		return MaxInt64
@@ -91,14 +152,111 @@ func pow(a, b int64) int64 {
 	} else if b == 0 {
 		return 1
 	}
-	if b > 15 && (a > 15 || a < -15) {
+	if b < 0 {
 		return MaxInt64
+	} else if a == 1 {
+		return 1
+	} else if a == -1 && b%2 == 0 {
+		return 1
+	} else if a == -1 {
+		return -1
 	}
-	if p := math.Pow(float64(a), float64(b)); math.Abs(p) > MaxInt64 {
+	// |a| > 1, so the result overflows in at most 63 multiplications
+	r := int64(1)
+	for i := int64(0); i < b; i++ {
+		var ok bool
+		if r, ok = mul64(r, a); !ok {
+			return MaxInt64
+		}
+	}
+	return r
+}
+
+// choose returns the binomial coefficient C(n, k) for 0 <= k <= n, or MaxInt64 if the
+// inputs are invalid or the result overflows.
+func choose(n, k int64) int64 {
+	if k < 0 || n < k {
 		return MaxInt64
-	} else {
-		return int64(p)
 	}
+	if k > n-k {
+		k = n - k
+	}
+	r := int64(1)
+	for i := int64(0); i < k; i++ {
+		// r * (n-i) / (i+1) is C(n, i+1), so (i+1) / g divides n-i
+		g := gcd(r, i+1)
+		var ok bool
+		if r, ok = mul64(r/g, (n-i)/((i+1)/g)); !ok {
+			return MaxInt64
+		}
+	}
+	return r
+}
+
+// perm returns the number of k-permutations of n, P(n, k) = n! / (n-k)! for 0 <= k <= n,
+// or MaxInt64 if the inputs are invalid or the result overflows.
+func perm(n, k int64) int64 {
+	if k < 0 || n < k {
+		return MaxInt64
+	}
+	r := int64(1)
+	for i := int64(0); i < k; i++ {
+		var ok bool
+		if r, ok = mul64(r, n-i); !ok {
+			return MaxInt64
+		}
+	}
+	return r
+}
+
+// perfectPower returns c and the largest k such that a = c^k for a >= 2, or 1 and 0
+// for a == 1, and MaxInt64 and 0 for other a.
+func perfectPower(a int64) (int64, int64) {
+	if a == 1 {
+		return 1, 0
+	} else if a < 1 {
+		return MaxInt64, 0
+	}
+	for k := int64(62); k > 1; k-- {
+		if c := root(a, k); c != MaxInt64 {
+			return c, k
+		}
+	}
+	return a, 1
+}
+
+// squarefree returns k and c such that n = k^2 * c for positive n, and c is squarefree.
+func squarefree(n int64) (int64, int64) {
+	k := int64(1)
+	for i := int64(2); i*i <= n; i++ {
+		for n%(i*i) == 0 {
+			n /= i * i
+			k *= i
+		}
+	}
+	return k, n
+}
+
+// mul64 returns a * b, and false if the result doesn't fit into int64. MinInt64 is
+// treated as an overflow, as it cannot be negated.
+func mul64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if c/b != a || c == -MaxInt64-1 || a == -MaxInt64-1 || b == -MaxInt64-1 {
+		return 0, false
+	}
+	return c, true
+}
+
+// add64 returns a + b, and false if the result doesn't fit into int64 (see mul64).
+func add64(a, b int64) (int64, bool) {
+	c := a + b
+	if (b > 0 && c < a) || (b < 0 && c > a) || c == -MaxInt64-1 {
+		return 0, false
+	}
+	return c, true
 }
 
 func gcd(a, b int64) int64 {
@@ -111,3 +269,47 @@ func gcd(a, b int64) int64 {
 	}
 	return gcd(b, a%b)
 }
+
+// abs returns |a|.
+func abs(a int64) int64 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// mulMod returns a * b mod m for 0 <= a, b < m.
+func mulMod(a, b, m int64) int64 {
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	return int64(bits.Rem64(hi, lo, uint64(m)))
+}
+
+// powMod returns a^e mod m for 0 <= a < m and e >= 0.
+func powMod(a, e, m int64) int64 {
+	r := 1 % m
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = mulMod(r, a, m)
+		}
+		a = mulMod(a, a, m)
+	}
+	return r
+}
+
+// invMod returns x such that a * x = 1 mod m for 0 <= a < m, and false if a and m are
+// not coprime.
+func invMod(a, m int64) (int64, bool) {
+	// Extended Euclidean algorithm, keeping r = x * a mod m
+	r0, r1, x0, x1 := m, a, int64(0), int64(1)
+	for r1 != 0 {
+		q := r0 / r1
+		r0, r1 = r1, r0-q*r1
+		x0, x1 = x1, x0-q*x1
+	}
+	if r0 != 1 {
+		return 0, false
+	} else if x0 < 0 {
+		x0 += m
+	}
+	return x0, true
+}
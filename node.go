@@ -18,7 +18,21 @@ const (
 	OpPow
 	OpFact // Unary ops start here
 	OpSqrt
-	OpMinus // unary minus
+	OpMinus      // unary minus
+	OpDoubleFact // n!! = n * (n-2) * ...
+	OpSubfact    // !n, the number of derangements of n elements
+	OpPrimorial  // n#, the product of all primes up to n
+	OpFloor
+	OpCeil
+	OpRound // rounds half away from zero
+	OpRecip // 1/x
+	OpAbs
+	OpIntDiv // floor division, binary like the ones below
+	OpMod    // remainder of OpIntDiv, with the sign of the divisor
+	OpChoose // C(n, k), the binomial coefficient
+	OpPerm   // P(n, k) = n! / (n-k)!
+	OpRoot   // root(n, x), the n-th root of x
+	OpLog    // log_b(x), the logarithm of x to base b
 )
 
 var opNames = map[Op]string{
@@ -31,16 +45,36 @@ var opNames = map[Op]string{
 	OpFact:  "!",
 	OpSqrt:  "sqrt",
 	OpMinus: "--",
+
+	OpDoubleFact: "!!",
+	OpSubfact:    "subfact",
+	OpPrimorial:  "#",
+	OpFloor:      "floor",
+	OpCeil:       "ceil",
+	OpRound:      "round",
+	OpRecip:      "recip",
+	OpAbs:        "abs",
+	OpIntDiv:     "div",
+	OpMod:        "mod",
+	OpChoose:     "C",
+	OpPerm:       "P",
+	OpRoot:       "root",
+	OpLog:        "log",
 }
 
 // unary returns true for unary operators
 func (op Op) unary() bool {
-	return op >= OpFact
+	return op != OpNull && !op.binary()
 }
 
 // binary returns true for binary operators
 func (op Op) binary() bool {
-	return op >= OpAdd && op <= OpPow
+	return op >= OpAdd && op <= OpPow || op >= OpIntDiv && op <= OpLog
+}
+
+// rounding returns true for operators rounding their argument to an integer
+func (op Op) rounding() bool {
+	return op == OpFloor || op == OpCeil || op == OpRound
 }
 
 // String returns string representation for op
@@ -67,9 +101,13 @@ type Value interface {
 // Node represents a formula parse tree, storing value (for a leaf) or
 // operand with left and right sub-nodes. Nodes with unary operators will have their
 // right sub-node nil, which is checked by Node.valid().
+// Leafs written as decimals, like .5 or .(3), and residues of numbers written with more
+// than the modulus, like 12 mod 7, store the literal in lit, so that they are printed
+// the same way.
 type Node struct {
 	left, right *Node
 	val         Value
+	lit         string
 	op          Op
 }
 
@@ -77,7 +115,7 @@ type Node struct {
 func (n *Node) valid() bool {
 	if n.op == OpNull {
 		return n.left == nil && n.right == nil
-	} else if n.op <= OpPow {
+	} else if n.op.binary() {
 		return n.left != nil && n.right != nil
 	} else {
 		return n.left != nil && n.right == nil
@@ -98,6 +136,32 @@ func newValNode(val Value) *Node {
 	return &Node{val: val}
 }
 
+// newLitNode creates a new value Node from a number written as s, which is either
+// a rational a/b or a decimal.
+func newLitNode(s string) (*Node, error) {
+	v, err := newRationalFromString(s)
+	if err != nil {
+		return nil, err
+	}
+	val, err := leafValue(v)
+	if err != nil {
+		return nil, err
+	}
+	n := newValNode(val)
+	if strings.Contains(s, ".") || modulus > 0 && n.literal() != s {
+		n.lit = s
+	}
+	return n, nil
+}
+
+// literal returns the value of a leaf as it should be written in formulas.
+func (n *Node) literal() string {
+	if n.lit != "" {
+		return n.lit
+	}
+	return n.val.String()
+}
+
 // newIntNode creates a new value Node from an integer.
 func newIntNode(val int64) *Node {
 	r, _ := newRational(val, 1)
@@ -124,7 +188,7 @@ func (n *Node) ToPolish() string {
 		return fmt.Sprintf("invalid formula: '%s'", n)
 	}
 	if n.op == OpNull {
-		return n.val.String()
+		return n.literal()
 	} else {
 		var s string
 		if n.op != OpMinus {
@@ -145,37 +209,28 @@ func (n *Node) ToPolish() string {
 func parseNodeFromString(s string) (*Node, string, error) {
 	s = strings.TrimSpace(s)
 	// Try to parse rational first
-	if ind := ratRx.FindStringIndex(s); ind != nil {
-		v, err := newRationalFromString(strings.TrimSpace(s[:ind[1]]))
+	if end := numberEnd(s); end >= 0 {
+		n, err := newLitNode(strings.TrimSpace(s[:end]))
 		if err != nil {
-			return nil, s[ind[1]:], err
+			return nil, s[end:], err
 		}
-		return newValNode(v), s[ind[1]:], nil
+		return n, s[end:], nil
 	}
 	if s == "" {
 		return nil, "", fmt.Errorf("empty string")
 	}
+	// Operators are matched by the longest name, so that -- is not taken for -
 	var op Op
-	if strings.HasPrefix(s, "sqrt") {
-		op = OpSqrt
-		s = s[4:]
-	} else if strings.HasPrefix(s, "--") {
-		op = OpMinus
-		s = s[2:]
-	} else {
-		for k := range opNames {
-			if opNames[k] == s[:1] {
-				op = k
-				if op == OpMinus {
-					op = OpSub
-				}
-			}
-		}
-		if op == OpNull {
-			return nil, s[1:], fmt.Errorf("unrecognized operator in '%s'", s)
+	var name string
+	for k, n := range opNames {
+		if k != OpNull && strings.HasPrefix(s, n) && len(n) > len(name) {
+			op, name = k, n
 		}
-		s = s[1:]
 	}
+	if op == OpNull {
+		return nil, s[1:], fmt.Errorf("unrecognized operator in '%s'", s)
+	}
+	s = s[len(name):]
 	if s == "" {
 		return nil, s, fmt.Errorf("first operand missing")
 	}
@@ -195,10 +250,26 @@ func parseNodeFromString(s string) (*Node, string, error) {
 	}
 }
 
-var ratRx *regexp.Regexp // Regular expression for a rational number
+var ratRx *regexp.Regexp // Regular expression for a rational or decimal number, see setBase
+
+// numberEnd returns the end of a rational or decimal number at the start of s, or -1 if
+// there is none. In bases above 10, words like div are operators, not numbers.
+func numberEnd(s string) int {
+	ind := ratRx.FindStringIndex(s)
+	if ind == nil {
+		return -1
+	} else if numBase > 10 {
+		if ind[1] < len(s) && isLetter(s[ind[1]]) {
+			return -1
+		} else if _, ok := opByName(strings.TrimSpace(s[:ind[1]])); ok {
+			return -1
+		}
+	}
+	return ind[1]
+}
 
-func init() {
-	ratRx = regexp.MustCompile("^\\s*-?[0-9]+(/[0-9]+)?")
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
 }
 
 // Depth returns distance of the deepest leaf to the root.
@@ -228,10 +299,10 @@ func (n *Node) Equal(n1 *Node) bool {
 }
 
 // Eval evaluates formula value, and raises an error if the result is invalid
-// or cannot be represented by a rational.
+// or cannot be represented by a rational. The error wraps one of the Err* sentinels.
 func (n *Node) Eval() (Value, error) {
 	if !n.valid() {
-		return rational{}, fmt.Errorf("invalid formula %s", n)
+		return rational{}, fmt.Errorf("%w %s", ErrInvalidNode, n)
 	}
 	if n.op == OpNull {
 		return n.val, nil
@@ -254,6 +325,65 @@ func (n *Node) Eval() (Value, error) {
 	}
 }
 
+// Step is a single entry of an evaluation trace: a sub-formula and its value.
+type Step struct {
+	Node *Node
+	Val  Value
+}
+
+// StepError reports the sub-formula on which evaluation failed and why.
+type StepError struct {
+	Node *Node
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("cannot evaluate %s: %s", e.Node, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Trace evaluates n like Eval, but also returns every sub-formula in evaluation order
+// (operands before their operator) together with its value. If evaluation fails, the
+// steps completed so far are returned with a *StepError pointing to the failed sub-node.
+func (n *Node) Trace() ([]Step, error) {
+	var steps []Step
+	_, err := n.trace(&steps)
+	return steps, err
+}
+
+// trace does the actual work for Trace, appending completed steps to steps.
+func (n *Node) trace(steps *[]Step) (Value, error) {
+	if !n.valid() {
+		return nil, &StepError{Node: n, Err: ErrInvalidNode}
+	}
+	var v Value
+	if n.op == OpNull {
+		v = n.val
+	} else {
+		left, err := n.left.trace(steps)
+		if err != nil {
+			return nil, err
+		}
+		if n.op.binary() {
+			var right Value
+			if right, err = n.right.trace(steps); err != nil {
+				return nil, err
+			}
+			v, err = left.PerformBinary(n.op, right)
+		} else {
+			v, err = left.PerformUnary(n.op)
+		}
+		if err != nil {
+			return nil, &StepError{Node: n, Err: err}
+		}
+	}
+	*steps = append(*steps, Step{Node: n, Val: v})
+	return v, nil
+}
+
 // transformDuo transorms all expressions of the form (op1 a) op2 (op3 b) into op4 (a op5 b),
 // and leaves other expressions intact. In the form above, (OpNull x) is treated as x.
 func (n *Node) transformDuo(op1, op2, op3, op4, op5 Op) *Node {
@@ -283,6 +413,27 @@ func (n *Node) transformDuo(op1, op2, op3, op4, op5 Op) *Node {
 	}
 }
 
+// sqrtMergeable returns true if n, which is sqrt(a) * sqrt(b) or sqrt(a) / sqrt(b), can be
+// written as sqrt(a * b) or sqrt(a / b). This is decided by the formula alone, whatever
+// values the search uses: a and b should be nonnegative rational numbers, and a * b or
+// a / b should not overflow. Other formulas may be negative, as sqrt(-1) * sqrt(-1) = -1.
+func (n *Node) sqrtMergeable() bool {
+	if n.op != OpMul && n.op != OpDiv || n.left.op != OpSqrt || n.right.op != OpSqrt {
+		return false
+	}
+	a, b := n.left.left, n.right.left
+	if a.op != OpNull || b.op != OpNull {
+		return false
+	}
+	ra, ok1 := toRational(a.val)
+	rb, ok2 := toRational(b.val)
+	if !ok1 || !ok2 || ra.Negative() || rb.Negative() {
+		return false
+	}
+	_, err := ra.PerformBinary(n.op, rb)
+	return err == nil
+}
+
 // transformTrio transforms an expression of the form a op1 (b op2 c) into (a op3 b) op4 c,
 // and leaves other expressions intact.
 func (n *Node) transformTrio(op1, op2, op3, op4 Op) *Node {
@@ -299,6 +450,12 @@ func (n *Node) Simplify() *Node {
 	var n1 *Node
 	if n.op == OpMinus && n.left.op == OpMinus {
 		n1 = n.left.left.Simplify()
+	} else if n.op.rounding() && n.left.op.rounding() {
+		n1 = n.left.Simplify()
+	} else if n.op == OpRecip && n.left.op == OpRecip {
+		n1 = n.left.left.Simplify()
+	} else if n.op == OpAbs && (n.left.op == OpMinus || n.left.op == OpAbs) {
+		n1 = &Node{op: OpAbs, left: n.left.left.Simplify()}
 	} else if n.op == OpPow && n.left.op == OpMinus {
 		e, err := n.right.Eval()
 		if err == nil && e.Even() {
@@ -323,6 +480,9 @@ func (n *Node) Simplify() *Node {
 			{OpSqrt, OpMul, OpSqrt, OpSqrt, OpMul},
 			{OpSqrt, OpDiv, OpSqrt, OpSqrt, OpDiv},
 		} {
+			if t[0] == OpSqrt && !n1.sqrtMergeable() {
+				continue
+			}
 			n1 = n1.transformDuo(t[0], t[1], t[2], t[3], t[4])
 		}
 		for _, t := range [][4]Op{
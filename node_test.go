@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -200,3 +201,65 @@ func TestNodeSimplify(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeSimplifySqrt(t *testing.T) {
+	assert := assert.New(t)
+	for polish, simple := range map[string]string{
+		"* sqrt 2 sqrt 8":                   "sqrt(2 * 8)",
+		"/ sqrt 8 sqrt 2":                   "sqrt(8 / 2)",
+		"* sqrt -1 sqrt -1":                 "sqrt(-1) * sqrt(-1)",
+		"/ sqrt 2 sqrt 0":                   "sqrt(2) / sqrt(0)",
+		"* sqrt + 1 1 sqrt 8":               "sqrt(1 + 1) * sqrt(8)",
+		"* sqrt 1/2 sqrt 3/4":               "sqrt((1/2) * (3/4))",
+		"* sqrt 4294967296 sqrt 4294967296": "sqrt(4294967296) * sqrt(4294967296)",
+	} {
+		n, err := FromPolish(polish)
+		if assert.NoError(err, polish) {
+			assert.Equal(simple, n.Simplify().String(), polish)
+		}
+	}
+}
+
+func TestNodeTrace(t *testing.T) {
+	assert := assert.New(t)
+	n, err := FromPolish("* + 1 2 ! 3")
+	assert.NoError(err)
+	steps, err := n.Trace()
+	assert.NoError(err)
+	var got []string
+	for _, s := range steps {
+		got = append(got, fmt.Sprintf("%s = %s", s.Node, s.Val))
+	}
+	assert.Equal([]string{"1 = 1", "2 = 2", "1 + 2 = 3", "3 = 3", "3! = 6", "(1 + 2) * 3! = 18"}, got)
+
+	n, err = FromPolish("+ 1 / 2 - 3 3")
+	assert.NoError(err)
+	steps, err = n.Trace()
+	assert.Error(err)
+	assert.Len(steps, 5)
+	se, ok := err.(*StepError)
+	assert.True(ok)
+	assert.Equal("2 / (3 - 3)", se.Node.String())
+}
+
+func TestNodeDecimals(t *testing.T) {
+	assert := assert.New(t)
+	n, err := FromPolish("/ 4 .4")
+	assert.NoError(err)
+	assert.Equal("4 / .4", n.String())
+	assert.Equal("/ 4 .4", n.ToPolish())
+	v, err := n.Eval()
+	assert.NoError(err)
+	assert.Equal(rat("10"), v)
+
+	n, err = FromInfix(".(3) + 1.2(3) * 2")
+	assert.NoError(err)
+	assert.Equal(".(3) + (1.2(3) * 2)", n.String())
+	assert.Equal(".(3) 1.2(3) 2 * +", n.ToRPN())
+	v, err = n.Eval()
+	assert.NoError(err)
+	assert.Equal(rat("14/5"), v)
+
+	assert.Equal([]string{".12", ".(12)", ".1(2)", "1.2", "1.(2)"}, decimalLiterals("12"))
+	assert.Equal([]string{".(90)"}, decimalLiterals("90"))
+}
@@ -0,0 +1,372 @@
+// This file contains parsers and printers for reverse Polish and infix notations.
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// FromRPN parses a node written in reverse Polish notation, e.g. "1 2 + 3 ! *".
+// Numbers and operators must be separated by spaces. As in FromPolish, rationals are
+// written as a/b and unary minus as --.
+func FromRPN(s string) (*Node, error) {
+	var stack []*Node
+	for i, t := range strings.Fields(s) {
+		if numberEnd(t) == len(t) {
+			n, err := newLitNode(t)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse '%s': %s", s, err)
+			}
+			stack = append(stack, n)
+			continue
+		}
+		op, ok := opByName(t)
+		if !ok {
+			return nil, fmt.Errorf("cannot parse '%s': unrecognized operator '%s'", s, t)
+		}
+		arity := 1
+		if op.binary() {
+			arity = 2
+		}
+		if len(stack) < arity {
+			return nil, fmt.Errorf("cannot parse '%s': stack underflow at token %d '%s'", s, i+1, t)
+		}
+		args := stack[len(stack)-arity:]
+		stack = stack[:len(stack)-arity]
+		n := &Node{op: op, left: args[0]}
+		if arity == 2 {
+			n.right = args[1]
+		}
+		stack = append(stack, n)
+	}
+	if len(stack) == 0 {
+		return nil, fmt.Errorf("cannot parse '%s': empty formula", s)
+	} else if len(stack) > 1 {
+		return nil, fmt.Errorf("cannot parse '%s': %d leftover operand(s) on the stack", s, len(stack)-1)
+	}
+	return stack[0], nil
+}
+
+// ToRPN is an opposite of FromRPN: it returns a node written in reverse Polish notation.
+func (n *Node) ToRPN() string {
+	if !n.valid() {
+		return fmt.Sprintf("invalid formula: '%s'", n)
+	}
+	if n.op == OpNull {
+		return n.literal()
+	}
+	s := n.left.ToRPN()
+	if n.right != nil {
+		s += " " + n.right.ToRPN()
+	}
+	return s + " " + n.op.String()
+}
+
+// FromInfix parses a node written in the usual infix notation, as printed by Node.String.
+// Since leafs are not distinguished from operations there, 3/4 and 1/4 are parsed as
+// division, 1/(4) as reciprocal and -3 as unary minus.
+func FromInfix(s string) (*Node, error) {
+	p := &infixParser{tokens: tokenizeInfix(s)}
+	n, err := p.expr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected '%s'", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse '%s': %s", s, err)
+	}
+	return n, nil
+}
+
+// tokenizeInfix splits s into numbers, names and single-character operators. 1/ followed
+// by ( without a space is a single token, the reciprocal (see Node.String), and so is !!,
+// the double factorial.
+func tokenizeInfix(s string) []string {
+	var tokens []string
+	r := []rune(s)
+	for i := 0; i < len(r); {
+		j := i + 1
+		switch {
+		case unicode.IsSpace(r[i]):
+			i++
+			continue
+		case startsNumber(r, i):
+			j = scanNumber(r, i)
+			if string(r[i:j]) == "1" && j+1 < len(r) && r[j] == '/' && r[j+1] == '(' {
+				j++
+			}
+		case unicode.IsLetter(r[i]):
+			for j < len(r) && unicode.IsLetter(r[j]) {
+				j++
+			}
+		case r[i] == '!':
+			if j < len(r) && r[j] == '!' {
+				j++
+			}
+		}
+		tokens = append(tokens, string(r[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+// startsNumber returns true if a number starts at r[i]. In bases above 10, words made of
+// digits are numbers unless they are names like abs.
+func startsNumber(r []rune, i int) bool {
+	if r[i] == '.' || r[i] >= '0' && r[i] <= '9' {
+		return isBaseDigit(r[i]) || r[i] == '.'
+	} else if !isBaseDigit(r[i]) {
+		return false
+	}
+	j := i
+	for j < len(r) && unicode.IsLetter(r[j]) {
+		if !isBaseDigit(r[j]) {
+			return false
+		}
+		j++
+	}
+	_, ok := opByName(string(r[i:j]))
+	return !ok
+}
+
+// scanNumber returns the end of a number starting at r[i], which is either an integer
+// or a decimal like 1.25, .5 or 1.2(3).
+func scanNumber(r []rune, i int) int {
+	digits := func(i int) int {
+		for i < len(r) && isBaseDigit(r[i]) {
+			i++
+		}
+		return i
+	}
+	i = digits(i)
+	if i == len(r) || r[i] != '.' {
+		return i
+	}
+	i = digits(i + 1)
+	if i < len(r) && r[i] == '(' {
+		if j := digits(i + 1); j > i+1 && j < len(r) && r[j] == ')' {
+			return j + 1
+		}
+	}
+	return i
+}
+
+// infixParser is a recursive descent parser for the infix notation.
+type infixParser struct {
+	tokens []string
+	pos    int
+}
+
+// peek returns the current token, or "" at the end of input.
+func (p *infixParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// expect skips the current token if it is t, and returns an error otherwise.
+func (p *infixParser) expect(t string) error {
+	if p.peek() != t {
+		return fmt.Errorf("expected '%s' at token %d", t, p.pos+1)
+	}
+	p.pos++
+	return nil
+}
+
+// binary parses a left-associative chain of ops with operands parsed by next.
+func (p *infixParser) binary(next func() (*Node, error), ops ...Op) (*Node, error) {
+	n, err := next()
+	for err == nil {
+		op, ok := opByName(p.peek())
+		if !ok || !hasOp(ops, op) {
+			return n, nil
+		}
+		p.pos++
+		var right *Node
+		if right, err = next(); err == nil {
+			n = newNode(n, op, right)
+		}
+	}
+	return nil, err
+}
+
+// hasOp returns true if op is one of ops.
+func hasOp(ops []Op, op Op) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// expr := term {(+|-) term}
+func (p *infixParser) expr() (*Node, error) {
+	return p.binary(p.term, OpAdd, OpSub)
+}
+
+// term := unary {(*|/|div|mod) unary}
+func (p *infixParser) term() (*Node, error) {
+	return p.binary(p.unary, OpMul, OpDiv, OpIntDiv, OpMod)
+}
+
+// unary := - unary | power
+func (p *infixParser) unary() (*Node, error) {
+	if p.peek() == "-" {
+		p.pos++
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return newNode(n, OpMinus, nil), nil
+	}
+	return p.power()
+}
+
+// power := postfix [^ unary]
+func (p *infixParser) power() (*Node, error) {
+	n, err := p.postfix()
+	if err != nil || p.peek() != "^" {
+		return n, err
+	}
+	p.pos++
+	right, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return newNode(n, OpPow, right), nil
+}
+
+// postfix := primary {! | !! | #}
+func (p *infixParser) postfix() (*Node, error) {
+	n, err := p.primary()
+	for err == nil {
+		op, ok := opByName(p.peek())
+		if !ok || !hasOp([]Op{OpFact, OpDoubleFact, OpPrimorial}, op) {
+			break
+		}
+		p.pos++
+		n = newNode(n, op, nil)
+	}
+	return n, err
+}
+
+// primary := number | name(expr) | name2(expr, expr) | log_primary(expr) | ⌊expr⌋ | ⌈expr⌉ |
+// |expr| | (expr) | !primary | !!primary | 1/(expr), where name is one of sqrt, round,
+// floor, ceil, recip or abs, and name2 is one of C, P or root
+func (p *infixParser) primary() (*Node, error) {
+	t := p.peek()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of input")
+	case t == "!" || t == "!!":
+		p.pos++
+		n, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		if t == "!!" {
+			n = newNode(n, OpSubfact, nil)
+		}
+		return newNode(n, OpSubfact, nil), nil
+	case t == "1/":
+		p.pos++
+		n, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		return newNode(n, OpRecip, nil), nil
+	case numberEnd(t) == len(t):
+		p.pos++
+		return newLitNode(t)
+	case t == "sqrt" || t == "round" || t == "floor" || t == "ceil" || t == "recip" || t == "abs":
+		op, _ := opByName(t)
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		return p.enclosed(")", op)
+	case t == "C" || t == "P" || t == "root":
+		op, _ := opByName(t)
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		left, err := p.expr()
+		if err == nil {
+			err = p.expect(",")
+		}
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.enclosed(")", OpNull)
+		if err != nil {
+			return nil, err
+		}
+		return newNode(left, op, right), nil
+	case t == "log":
+		p.pos++
+		if err := p.expect("_"); err != nil {
+			return nil, err
+		}
+		base, err := p.primary()
+		if err == nil {
+			err = p.expect("(")
+		}
+		if err != nil {
+			return nil, err
+		}
+		n, err := p.enclosed(")", OpNull)
+		if err != nil {
+			return nil, err
+		}
+		return newNode(base, OpLog, n), nil
+	case t == "⌊":
+		p.pos++
+		return p.enclosed("⌋", OpFloor)
+	case t == "⌈":
+		p.pos++
+		return p.enclosed("⌉", OpCeil)
+	case t == "|":
+		p.pos++
+		return p.enclosed("|", OpAbs)
+	case t == "(":
+		p.pos++
+		return p.enclosed(")", OpNull)
+	default:
+		return nil, fmt.Errorf("unexpected '%s' at token %d", t, p.pos+1)
+	}
+}
+
+// enclosed parses an expression followed by the closing token, and applies unary op
+// to it unless op is OpNull.
+func (p *infixParser) enclosed(closing string, op Op) (*Node, error) {
+	n, err := p.expr()
+	if err == nil {
+		err = p.expect(closing)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if op == OpNull {
+		return n, nil
+	}
+	return newNode(n, op, nil), nil
+}
+
+// notations maps notation names accepted by the command line to parsers.
+var notations = map[string]func(string) (*Node, error){
+	"prefix":  FromPolish,
+	"postfix": FromRPN,
+	"infix":   FromInfix,
+}
+
+// parseFormula parses s written in the given notation.
+func parseFormula(notation, s string) (*Node, error) {
+	parse, ok := notations[notation]
+	if !ok {
+		return nil, fmt.Errorf("unknown notation '%s', should be prefix, postfix or infix", notation)
+	}
+	return parse(s)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeRPN(t *testing.T) {
+	assert := assert.New(t)
+	n, err := FromRPN("1 2 + 3 !  *")
+	assert.NoError(err)
+	assert.Equal("(1 + 2) * 3!", n.String())
+	assert.Equal("1 2 + 3 ! *", n.ToRPN())
+	for _, p := range []string{"1", "-3/4", "-- 1", "/ sqrt 2 ^ ! 3/4 -- -5/6", "- - 1 2 - 3 4"} {
+		node, err := FromPolish(p)
+		assert.NoError(err)
+		n1, err := FromRPN(node.ToRPN())
+		if assert.NoError(err, "parsing %s", node.ToRPN()) {
+			assert.True(n1.Equal(node), "parsing %s", node.ToRPN())
+		}
+	}
+	for _, s := range []string{"", "+", "1 +", "1 2", "1 2 3 +", "1 x", "1 0/0"} {
+		_, err := FromRPN(s)
+		assert.Error(err, "parsing '%s'", s)
+	}
+}
+
+func TestNodeInfix(t *testing.T) {
+	assert := assert.New(t)
+	for _, p := range []string{
+		"1",
+		"-- 1",
+		"- - 1 2 - 3 4",
+		"* + 1 2 ! 3",
+		"^ -- 2 2",
+		"-- ^ 2 2",
+		"-- ! sqrt 9",
+		"^ 2 ^ 3 2",
+		"^ ^ 2 3 2",
+		"! ! 3",
+		"/ * 1 2 * 3 4",
+		"^ 2 -- 3",
+		"!! 3",
+		"! !! 3",
+		"!! ! 3",
+		"# ! 3",
+		"! # 3",
+		"subfact subfact 3",
+		"! ! ! 3",
+		"!! !! 3",
+		"# !! 3",
+		"subfact ! 3",
+		"! subfact 3",
+		"-- !! 3",
+		"^ subfact 3 2",
+		"* 2 subfact 4",
+		"floor / 7 2",
+		"-- ceil / 7 2",
+		"^ round / 7 2 floor / 1 2",
+		"abs - 1 5",
+		"recip 4",
+		"recip + 1 2",
+		"/ 1 recip 4",
+		"recip ! 4",
+		"recip sqrt 4",
+		"^ recip 2 recip - 1 3",
+		"-- recip -- recip 4",
+		"* 3 recip recip 4",
+		"log recip 2 8",
+		"log 2 recip 8",
+		"C recip 2 1",
+		"! recip 4",
+		"abs -- abs - 1 5",
+		"* abs -- 2 abs 3",
+		"div 7 2",
+		"mod div 9 2 3",
+		"div 9 mod 7 4",
+		"- 1 mod -- 7 2",
+		"C 5 2",
+		"* 2 P + 3 4 C 2 1",
+		"root 3 8",
+		"log 2 8",
+		"* log + 1 1 8 2",
+		"log log 2 4 ! 4",
+		"- root ! 3 + 1 7 2",
+	} {
+		node, err := FromPolish(p)
+		assert.NoError(err)
+		n1, err := FromInfix(node.String())
+		if assert.NoError(err, "parsing %s", node) {
+			assert.True(n1.Equal(node), "parsing %s gives %s", node, n1.ToPolish())
+		}
+	}
+	n, err := FromInfix("3/4 - -2")
+	assert.NoError(err)
+	assert.Equal("- / 3 4 -- 2", n.ToPolish())
+	n, err = FromInfix("3!! + !!3 + 3!!!")
+	assert.NoError(err)
+	assert.Equal("+ + !! 3 subfact subfact 3 ! !! 3", n.ToPolish())
+	n, err = FromInfix("1/(4) + 1/4 + 1 / 4 + 1/(1 + 1)!")
+	assert.NoError(err)
+	assert.Equal("+ + + recip 4 / 1 4 / 1 4 ! recip + 1 1", n.ToPolish())
+	for _, s := range []string{"", "+", "1 +", "1 2", "(1", "sqrt 2", "1 $ 2", "2 ^"} {
+		_, err := FromInfix(s)
+		assert.Error(err, "parsing '%s'", s)
+	}
+}
+
+// TestNodeInfixGenerated checks that formulas printed by Node.String are parsed back by
+// FromInfix with the same values, for formulas made of every operator and leafs which
+// FromInfix reads as operators, like -8 or 1/3.
+func TestNodeInfixGenerated(t *testing.T) {
+	assert := assert.New(t)
+	var ops []Op
+	for op := range opNames {
+		if op != OpNull {
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	var leafs []*Node
+	for _, s := range []string{"2", "-8", "1/3", "-3/2", ".5"} {
+		n, err := newLitNode(s)
+		if assert.NoError(err, s) {
+			leafs = append(leafs, n)
+		}
+	}
+	combine := func(as, bs []*Node) []*Node {
+		var result []*Node
+		for _, op := range ops {
+			for _, a := range as {
+				if !op.binary() {
+					result = append(result, &Node{op: op, left: a})
+					continue
+				}
+				for _, b := range bs {
+					result = append(result, &Node{op: op, left: a, right: b})
+				}
+			}
+		}
+		return result
+	}
+	nodes := combine(leafs, leafs)
+	for _, n := range append(append(nodes, combine(nodes, leafs)...), combine(leafs, nodes)...) {
+		n1, err := FromInfix(n.String())
+		if !assert.NoError(err, "parsing %s", n) {
+			continue
+		}
+		v, err := n.Eval()
+		v1, err1 := n1.Eval()
+		if assert.Equal(err == nil, err1 == nil, "%s is %s", n, n1.ToPolish()) && err == nil {
+			assert.True(v.Equal(v1), "%s is %s", n, n1.ToPolish())
+		}
+	}
+}
+
+func TestNodeBase(t *testing.T) {
+	assert := assert.New(t)
+	withBase(16, func() {
+		for _, tc := range []struct{ polish, infix, val string }{
+			{"+ ab c", "ab + c", "b7"},
+			{"C c a", "C(c, a)", "42"},
+			{"abs - a f", "|a - f|", "5"},
+			{"div ff -- 10", "ff div -10", "-10"},
+			{"* sqrt 19 .8", "sqrt(19) * .8", "5/2"},
+		} {
+			n, err := FromPolish(tc.polish)
+			if !assert.NoError(err, tc.polish) {
+				continue
+			}
+			assert.Equal(tc.infix, n.String())
+			assert.Equal(tc.polish, n.ToPolish())
+			v, err := n.Eval()
+			assert.NoError(err)
+			assert.Equal(tc.val, v.String())
+			n1, err := FromInfix(n.String())
+			if assert.NoError(err, n.String()) {
+				assert.True(n1.Equal(n), n.String())
+			}
+			n1, err = FromRPN(n.ToRPN())
+			if assert.NoError(err, n.ToRPN()) {
+				assert.True(n1.Equal(n), n.ToRPN())
+			}
+		}
+		_, err := FromPolish("+ g 1")
+		assert.Error(err)
+	})
+}
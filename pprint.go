@@ -1,11 +1,21 @@
 // This file contains code for pretty-printing nodes.
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
-// return true if we need parenthesis around n.String() in places like _ */-^ n
+// return true if we need parenthesis around n.String() in places like _ */-^ n. Leafs
+// written with / or -, like 1/3 or -8, are operators for FromInfix, so they need them too.
 func (n *Node) needParenthesis() bool {
-	return n.op >= OpAdd && n.op <= OpPow
+	return n.op.binary() && !n.op.function() || n.op == OpRecip ||
+		n.op == OpNull && strings.ContainsAny(n.literal(), "/-")
+}
+
+// function returns true for binary operators written as name(a, b)
+func (op Op) function() bool {
+	return op == OpChoose || op == OpPerm || op == OpRoot || op == OpLog
 }
 
 // String returns a formula for n, sometimes (always *sigh*) with excessive paranthesis
@@ -21,14 +31,14 @@ func (n *Node) String() string {
 		}
 	}
 	if left == "" && right == "" {
-		return n.val.String()
+		return n.literal()
 	} else {
 		switch n.op {
 		case OpAdd:
 			return fmt.Sprintf("%s + %s", left, right)
 		case OpSub:
 			return fmt.Sprintf("%s - %s", left, right)
-		case OpMul, OpDiv:
+		case OpMul, OpDiv, OpIntDiv, OpMod:
 			if n.left.needParenthesis() {
 				left = "(" + left + ")"
 			}
@@ -38,15 +48,40 @@ func (n *Node) String() string {
 				left = "(" + left + ")"
 			}
 			return fmt.Sprintf("%s %s %s", left, opNames[n.op], right)
-		case OpFact:
-			if n.left.needParenthesis() || n.left.op == OpMinus {
+		case OpFact, OpDoubleFact, OpPrimorial:
+			if n.left.needParenthesis() || n.left.op == OpMinus || n.left.op == OpSubfact {
+				left = "(" + left + ")"
+			} else if n.op != OpPrimorial && (n.left.op == OpFact || n.left.op == OpDoubleFact) {
+				// 3!! is the double factorial, so (3!)! needs parenthesis
+				left = "(" + left + ")"
+			}
+			return left + opNames[n.op]
+		case OpSubfact:
+			if n.left.op != OpNull && n.left.op != OpSqrt || n.left.needParenthesis() {
+				left = "(" + left + ")"
+			}
+			return "!" + left
+		case OpSqrt, OpRound:
+			return fmt.Sprintf("%s(%s)", opNames[n.op], left)
+		case OpChoose, OpPerm, OpRoot:
+			return fmt.Sprintf("%s(%s, %s)", opNames[n.op], left, n.right)
+		case OpLog:
+			if n.left.op != OpNull || !n.left.val.IsInteger() || n.left.val.Negative() {
 				left = "(" + left + ")"
 			}
-			return fmt.Sprintf("%s!", left)
-		case OpSqrt:
-			return fmt.Sprintf("sqrt(%s)", left)
+			return fmt.Sprintf("log_%s(%s)", left, n.right)
+		case OpRecip:
+			// Unlike division and rational leafs like 1/2, written without spaces and always
+			// with parenthesis, so FromInfix can tell them apart
+			return "1/(" + left + ")"
+		case OpAbs:
+			return "|" + left + "|"
+		case OpFloor:
+			return "⌊" + left + "⌋"
+		case OpCeil:
+			return "⌈" + left + "⌉"
 		case OpMinus:
-			if n.left.needParenthesis() || n.left.op == OpFact {
+			if n.left.needParenthesis() || n.left.op == OpFact || n.left.op == OpDoubleFact || n.left.op == OpPrimorial {
 				left = "(" + left + ")"
 			}
 			return "-" + left
@@ -55,3 +90,111 @@ func (n *Node) String() string {
 		}
 	}
 }
+
+// LaTeX returns a formula for n in LaTeX, like \binom{5}{2} \cdot \sqrt[3]{8}, with
+// parenthesis where String has them, unless LaTeX groups the operands itself.
+func (n *Node) LaTeX() string {
+	if n.op == OpNull {
+		return latexLiteral(n.literal())
+	}
+	left := n.left.LaTeX()
+	var right string
+	if n.right != nil {
+		right = n.right.LaTeX()
+		if n.right.latexParenthesis() {
+			right = "(" + right + ")"
+		}
+	}
+	switch n.op {
+	case OpAdd:
+		return fmt.Sprintf("%s + %s", left, right)
+	case OpSub:
+		return fmt.Sprintf("%s - %s", left, right)
+	case OpMul, OpIntDiv, OpMod:
+		if n.left.latexParenthesis() {
+			left = "(" + left + ")"
+		}
+		return fmt.Sprintf("%s %s %s", left, latexNames[n.op], right)
+	case OpDiv:
+		return fmt.Sprintf(`\frac{%s}{%s}`, left, n.right.LaTeX())
+	case OpPow:
+		if n.left.needParenthesis() || n.left.op == OpMinus {
+			left = "(" + left + ")"
+		}
+		return fmt.Sprintf("%s^{%s}", left, n.right.LaTeX())
+	case OpFact, OpDoubleFact, OpPrimorial:
+		if n.left.needParenthesis() || n.left.op == OpMinus || n.left.op == OpSubfact {
+			left = "(" + left + ")"
+		} else if n.op != OpPrimorial && (n.left.op == OpFact || n.left.op == OpDoubleFact) {
+			left = "(" + left + ")"
+		}
+		return left + latexNames[n.op]
+	case OpSubfact:
+		if n.left.op != OpNull && n.left.op != OpSqrt || n.left.needParenthesis() {
+			left = "(" + left + ")"
+		}
+		return "!" + left
+	case OpSqrt:
+		return fmt.Sprintf(`\sqrt{%s}`, left)
+	case OpRound:
+		return fmt.Sprintf(`\operatorname{round}(%s)`, left)
+	case OpChoose:
+		return fmt.Sprintf(`\binom{%s}{%s}`, left, n.right.LaTeX())
+	case OpPerm:
+		return fmt.Sprintf("%s(%s, %s)", opNames[n.op], left, n.right.LaTeX())
+	case OpRoot:
+		return fmt.Sprintf(`\sqrt[%s]{%s}`, left, n.right.LaTeX())
+	case OpLog:
+		return fmt.Sprintf(`\log_{%s}(%s)`, left, n.right.LaTeX())
+	case OpRecip:
+		return fmt.Sprintf(`\frac{1}{%s}`, left)
+	case OpAbs:
+		return fmt.Sprintf(`\left|%s\right|`, left)
+	case OpFloor:
+		return fmt.Sprintf(`\lfloor %s \rfloor`, left)
+	case OpCeil:
+		return fmt.Sprintf(`\lceil %s \rceil`, left)
+	case OpMinus:
+		if n.left.latexParenthesis() || n.left.op == OpFact || n.left.op == OpDoubleFact || n.left.op == OpPrimorial {
+			left = "(" + left + ")"
+		}
+		return "-" + left
+	default:
+		return "<UNDEFINED>"
+	}
+}
+
+// latexNames are the names of operators which LaTeX writes differently from String.
+var latexNames = map[Op]string{
+	OpMul:        `\cdot`,
+	OpIntDiv:     `\operatorname{div}`,
+	OpMod:        `\bmod`,
+	OpFact:       "!",
+	OpDoubleFact: "!!",
+	OpPrimorial:  `\#`,
+}
+
+// latexParenthesis is needParenthesis for LaTeX, where fractions need none.
+func (n *Node) latexParenthesis() bool {
+	if n.op == OpDiv || n.op == OpRecip {
+		return false
+	} else if n.op == OpNull {
+		return strings.HasPrefix(n.literal(), "-")
+	}
+	return n.needParenthesis()
+}
+
+// latexLiteral writes a leaf written as s in LaTeX: 1/2 as \frac{1}{2}, and the
+// repeating part of decimals like 1.(3) with a line over it.
+func latexLiteral(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	if a, b, ok := strings.Cut(s, "/"); ok {
+		return fmt.Sprintf(`%s\frac{%s}{%s}`, sign, a, b)
+	} else if a, b, ok := strings.Cut(s, "("); ok {
+		return fmt.Sprintf(`%s%s\overline{%s}`, sign, a, strings.TrimSuffix(b, ")"))
+	}
+	return sign + s
+}
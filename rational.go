@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -15,7 +16,7 @@ type rational struct {
 // if b == 0.
 func newRational(a, b int64) (rational, error) {
 	if b == 0 {
-		return rational{}, fmt.Errorf("%d/0 is not a proper rational", a)
+		return rational{}, newOpError(ErrDivByZero, OpDiv, rational{a, 1}, rational{0, 1})
 	} else {
 		return rational{a, b}.normalize(), nil
 	}
@@ -36,34 +37,120 @@ func (r rational) normalize() rational {
 	return rational{n: n1 / g, d: d1 / g}
 }
 
-// newRationalFromString creates a normalized rational from a string "a/b", and
-// returns an error if it cannot be parsed.
+// newRationalFromString creates a normalized rational from a string "a/b" or a decimal
+// (see newRationalFromDecimal), and returns an error if it cannot be parsed.
 func newRationalFromString(s string) (rational, error) {
+	if strings.Contains(s, ".") {
+		return newRationalFromDecimal(s)
+	}
 	p := strings.Split(s, "/")
 	if len(p) > 2 {
 		return rational{}, fmt.Errorf("cannot convert %s to rational\n", s)
 	}
-	num, err := strconv.Atoi(p[0])
+	num, err := parseInt(p[0])
 	if err != nil {
 		return rational{}, fmt.Errorf("cannot convert %s to number: %s\n", p[0], err)
 	}
-	var denom int
+	var denom int64
 	if len(p) == 2 {
-		denom, err = strconv.Atoi(p[1])
+		denom, err = parseInt(p[1])
 		if err != nil {
 			return rational{}, fmt.Errorf("cannot convert %s to number: %s\n", p[1], err)
 		}
 	} else {
 		denom = 1
 	}
-	return newRational(int64(num), int64(denom))
+	return newRational(num, denom)
+}
+
+// maxDecimalDigits limits the number of digits in decimals to avoid overflows.
+const maxDecimalDigits = 9
+
+// newRationalFromDecimal creates a normalized rational from a decimal like 1.25, .5,
+// or with a repeating part in parenthesis: .(3) or 1.2(3). It returns an error if
+// s cannot be parsed.
+func newRationalFromDecimal(s string) (rational, error) {
+	m := decimalRx.FindStringSubmatch(s)
+	if m == nil || m[2] == "" && m[3] == "" && m[4] == "" {
+		return rational{}, fmt.Errorf("cannot convert %s to decimal", s)
+	}
+	if len(m[2])+len(m[3])+len(m[4]) > maxDecimalDigits {
+		return rational{}, fmt.Errorf("too many digits in %s", s)
+	}
+	// value = int + frac / b^len(frac) + rep / (b^len(frac) * (b^len(rep) - 1)) in base b
+	b := int64(numBase)
+	var n int64
+	if m[2]+m[3] != "" {
+		n, _ = parseInt(m[2] + m[3])
+	}
+	f := pow(b, int64(len(m[3])))
+	r := rational{n, f}
+	if m[4] != "" {
+		rep, _ := parseInt(m[4])
+		var err error
+		if r, err = r.Add(rational{rep, f * (pow(b, int64(len(m[4]))) - 1)}); err != nil {
+			return rational{}, err
+		}
+	}
+	if m[1] == "-" {
+		r = r.Minus()
+	}
+	return r.normalize(), nil
+}
+
+var decimalRx *regexp.Regexp // Regular expression for a decimal
+
+func init() {
+	setBase(10)
+}
+
+// numBase is the base of numbers read and written by the program, see setBase.
+var numBase int
+
+// setBase sets the base of numbers, from 2 to 36. Digits above 9 are lowercase letters,
+// so that numbers differ from operators like C: in base 16, C(c, a) is C(12, 10).
+func setBase(b int) error {
+	if b < 2 || b > 36 {
+		return fmt.Errorf("base should be from 2 to 36, not %d", b)
+	}
+	numBase = b
+	d := "0-9"
+	if b < 10 {
+		d = "0-" + strconv.Itoa(b-1)
+	} else if b > 10 {
+		d += "a-" + strconv.FormatInt(int64(b-1), b)
+	}
+	decimalRx = regexp.MustCompile(strings.Replace(`^(-?)([D]*)\.([D]*)(?:\(([D]+)\))?$`, "D", d, -1))
+	ratRx = regexp.MustCompile(strings.Replace(`^\s*-?([D]*\.[D]*(\([D]+\))?|[D]+(/[D]+)?)`, "D", d, -1))
+	return nil
+}
+
+// parseInt converts an integer written in the base numBase.
+func parseInt(s string) (int64, error) {
+	return strconv.ParseInt(s, numBase, 64)
+}
+
+// formatInt writes n in the base numBase.
+func formatInt(n int64) string {
+	return strconv.FormatInt(n, numBase)
+}
+
+// isBaseDigit returns true for digits of the base numBase.
+func isBaseDigit(c rune) bool {
+	switch {
+	case c >= '0' && c <= '9':
+		return c-'0' < rune(numBase)
+	case c >= 'a' && c <= 'z':
+		return c-'a'+10 < rune(numBase)
+	}
+	return false
 }
 
 func (r rational) String() string {
 	if r.d == 1 {
-		return strconv.FormatInt(r.n, 10)
+		return formatInt(r.n)
 	} else {
-		return fmt.Sprintf("%s/%s", strconv.FormatInt(r.n, 10), strconv.FormatInt(r.d, 10))
+		return fmt.Sprintf("%s/%s", formatInt(r.n), formatInt(r.d))
 	}
 }
 
@@ -76,8 +163,30 @@ func (r rational) PerformUnary(op Op) (Value, error) {
 		return r.Sqrt()
 	case OpMinus:
 		return r.Minus(), nil
+	case OpDoubleFact:
+		return r.lookup(OpDoubleFact, doubleFact)
+	case OpSubfact:
+		return r.lookup(OpSubfact, subfact)
+	case OpPrimorial:
+		return r.lookup(OpPrimorial, primorial)
+	case OpFloor:
+		return r.Floor(), nil
+	case OpCeil:
+		return r.Ceil(), nil
+	case OpRound:
+		return r.Round(), nil
+	case OpRecip:
+		if r.n == 0 {
+			return rational{}, newOpError(ErrDivByZero, OpRecip, r)
+		}
+		return rational{r.d, r.n}.normalize(), nil
+	case OpAbs:
+		if r.Negative() {
+			return r.Minus(), nil
+		}
+		return r.normalize(), nil
 	default:
-		return rational{}, fmt.Errorf("%s is not unary operator", op)
+		return rational{}, fmt.Errorf("%s is not unary operator: %w", op, ErrInvalidNode)
 	}
 }
 
@@ -85,21 +194,31 @@ func (r rational) PerformUnary(op Op) (Value, error) {
 func (r rational) PerformBinary(op Op, v Value) (Value, error) {
 	r1, ok := v.(rational)
 	if !ok {
-		return rational{}, fmt.Errorf("%v is not rational", v)
+		return rational{}, newOpError(ErrMismatch, op, r, v)
 	}
 	switch op {
 	case OpAdd:
-		return r.Add(r1), nil
+		return r.Add(r1)
 	case OpSub:
-		return r.Sub(r1), nil
+		return r.Sub(r1)
 	case OpMul:
-		return r.Mul(r1), nil
+		return r.Mul(r1)
 	case OpDiv:
 		return r.Div(r1)
 	case OpPow:
 		return r.Pow(r1)
+	case OpIntDiv, OpMod:
+		return r.intDiv(op, r1)
+	case OpChoose:
+		return r.combinatorial(op, r1, choose)
+	case OpPerm:
+		return r.combinatorial(op, r1, perm)
+	case OpRoot:
+		return r.Root(r1)
+	case OpLog:
+		return r.Log(r1)
 	default:
-		return rational{}, fmt.Errorf("%s is not binary operator", op)
+		return rational{}, fmt.Errorf("%s is not binary operator: %w", op, ErrInvalidNode)
 	}
 }
 
@@ -107,62 +226,115 @@ func (r rational) PerformBinary(op Op, v Value) (Value, error) {
 func (r rational) Equal(v Value) bool {
 	r1, ok := v.(rational)
 	if !ok {
-		return false
+		// Other values convert rationals to their kind (see toSurd), so let them compare
+		return v != nil && v.Equal(r)
 	}
 	return r.isEqual(r1)
 }
 
-// Add returns r + r1
-func (r rational) Add(r1 rational) rational {
-	if r.d == 1 && r1.d == 1 {
-		return rational{
-			n: r.n + r1.n,
-			d: 1,
-		}
-	} else {
-		return rational{
-			n: r.n*r1.d + r1.n*r.d,
-			d: r.d * r1.d,
-		}.normalize()
-	}
+// Add returns r + r1, or an error if the result overflows.
+func (r rational) Add(r1 rational) (rational, error) {
+	return r.add(r1, OpAdd)
 }
 
-func (r rational) Sub(r1 rational) rational {
-	return r.Add(rational{n: -r1.n, d: r1.d})
+func (r rational) Sub(r1 rational) (rational, error) {
+	return r.add(rational{n: -r1.n, d: r1.d}, OpSub)
+}
+
+// add returns r + r1, reporting overflows as errors of op.
+func (r rational) add(r1 rational, op Op) (rational, error) {
+	n1, ok1 := mul64(r.n, r1.d)
+	n2, ok2 := mul64(r1.n, r.d)
+	d, ok3 := mul64(r.d, r1.d)
+	n, ok4 := add64(n1, n2)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return rational{}, newOpError(ErrOverflow, op, r, r1)
+	}
+	return rational{n, d}.normalize(), nil
 }
 
-func (r rational) Mul(r1 rational) rational {
-	return rational{
-		n: r.n * r1.n,
-		d: r.d * r1.d,
-	}.normalize()
+func (r rational) Mul(r1 rational) (rational, error) {
+	return r.mul(r1, OpMul)
 }
 
 func (r rational) Div(r1 rational) (rational, error) {
 	if r1.n == 0 {
-		return rational{}, fmt.Errorf("division by 0: %s / %s", r, r1)
+		return rational{}, newOpError(ErrDivByZero, OpDiv, r, r1)
+	}
+	return r.mul(rational{r1.d, r1.n}.normalize(), OpDiv)
+}
+
+// mul returns r * r1, reporting overflows as errors of op. Both are reduced crosswise
+// first, so that the result overflows only if it doesn't fit into int64.
+func (r rational) mul(r1 rational, op Op) (rational, error) {
+	r, r1 = r.normalize(), r1.normalize()
+	if r.n == 0 || r1.n == 0 {
+		return rational{0, 1}, nil
+	}
+	g1, g2 := gcd(abs(r.n), r1.d), gcd(abs(r1.n), r.d)
+	n, ok1 := mul64(r.n/g1, r1.n/g2)
+	d, ok2 := mul64(r.d/g2, r1.d/g1)
+	if !ok1 || !ok2 {
+		var err error = newOpError(ErrOverflow, op, r, r1)
+		if op == OpDiv {
+			err = newOpError(ErrOverflow, op, r, rational{r1.d, r1.n}.normalize())
+		}
+		return rational{}, err
+	}
+	return rational{n, d}, nil
+}
+
+// intDiv returns floor(r / r1) for OpIntDiv, or the remainder r - r1 * floor(r / r1)
+// for OpMod. Both r and r1 should be integers.
+func (r rational) intDiv(op Op, r1 rational) (rational, error) {
+	r, r1 = r.normalize(), r1.normalize()
+	if r.d != 1 || r1.d != 1 {
+		return rational{}, newOpError(ErrDomain, op, r, r1)
+	} else if r1.n == 0 {
+		return rational{}, newOpError(ErrDivByZero, op, r, r1)
+	}
+	q, m := r.n/r1.n, r.n%r1.n
+	if m != 0 && (m < 0) != (r1.n < 0) {
+		q, m = q-1, m+r1.n
+	}
+	if op == OpMod {
+		return rational{m, 1}, nil
+	}
+	return rational{q, 1}, nil
+}
+
+// combinatorial applies op calculated by f, which returns MaxInt64 for invalid inputs
+// or overflows, to integers 0 <= r1 <= r.
+func (r rational) combinatorial(op Op, r1 rational, f func(n, k int64) int64) (rational, error) {
+	r, r1 = r.normalize(), r1.normalize()
+	if r.d != 1 || r1.d != 1 || r1.n < 0 || r.n < r1.n {
+		return rational{}, newOpError(ErrDomain, op, r, r1)
+	}
+	if v := f(r.n, r1.n); v == MaxInt64 {
+		return rational{}, newOpError(ErrOverflow, op, r, r1)
+	} else {
+		return rational{v, 1}, nil
 	}
-	return rational{
-		n: r.n * r1.d,
-		d: r.d * r1.n,
-	}.normalize(), nil
 }
 
 func (r rational) Pow(r1 rational) (rational, error) {
 	r1 = r1.normalize()
+	if r.n == 0 && r1.n == 0 {
+		return rational{}, newOpError(ErrDomain, OpPow, r, r1)
+	}
 	if r1.n < 0 {
 		if r.n == 0 {
-			return rational{}, fmt.Errorf("Cannot raise 0 to %d", r1.n)
+			return rational{}, newOpError(ErrDivByZero, OpPow, r, r1)
 		}
 		return rational{n: r.d, d: r.n}.Pow(r1.Minus())
 	}
 	n1 := pow(r.n, r1.n)
 	if n1 == MaxInt64 {
-		return rational{}, fmt.Errorf("Cannot calculate %d^%d", r.n, r1.n)
+		return rational{}, newOpError(ErrOverflow, OpPow, r, r1)
 	}
 	d1 := pow(r.d, r1.n)
 	if d1 == MaxInt64 {
-		return rational{}, fmt.Errorf("Cannot calculate %d^%d", r.d, r1.n)
+		return rational{}, newOpError(ErrOverflow, OpPow, r, r1)
 	}
 	if r1.d == 1 {
 		return rational{n1, d1}.normalize(), nil
@@ -170,28 +342,76 @@ func (r rational) Pow(r1 rational) (rational, error) {
 		n2, d2 := root(n1, r1.d), root(d1, r1.d)
 		if n2 != MaxInt64 && d2 != MaxInt64 {
 			return rational{n: n2, d: d2}.normalize(), nil
+		} else if n1 < 0 && r1.d%2 == 0 {
+			return rational{}, newOpError(ErrDomain, OpPow, r, r1)
 		} else {
-			return rational{}, fmt.Errorf("Cannot calculate root[%d] of %s (2), n2 = %d, d2 = %d", r1.d, rational{n: n1, d: d1}, n2, d2)
+			return rational{}, newOpError(ErrNotRational, OpPow, r, r1)
 		}
 	}
 }
 
 func (r rational) Fact() (rational, error) {
-	if r.d != 1 {
-		return rational{}, fmt.Errorf("Cannot calculate %s!", r)
+	if r.d != 1 || r.n < 0 {
+		return rational{}, newOpError(ErrDomain, OpFact, r)
 	}
 	if r.n == 1 || r.n == 2 {
 		return r, nil
 	}
 	if f := fact(r.n); f == MaxInt64 {
-		return rational{}, fmt.Errorf("Cannot calculate %d!", r)
+		return rational{}, newOpError(ErrOverflow, OpFact, r)
 	} else {
 		return rational{f, 1}, nil
 	}
 }
 
+// lookup applies op calculated by f, which returns MaxInt64 for invalid inputs,
+// to non-negative integer r.
+func (r rational) lookup(op Op, f func(int64) int64) (rational, error) {
+	if r.d != 1 || r.n < 0 {
+		return rational{}, newOpError(ErrDomain, op, r)
+	}
+	if v := f(r.n); v == MaxInt64 {
+		return rational{}, newOpError(ErrOverflow, op, r)
+	} else {
+		return rational{v, 1}, nil
+	}
+}
+
+// Floor returns the largest integer not greater than r.
+func (r rational) Floor() rational {
+	r = r.normalize()
+	q := r.n / r.d
+	if r.n%r.d != 0 && r.n < 0 {
+		q--
+	}
+	return rational{q, 1}
+}
+
+// Ceil returns the smallest integer not less than r.
+func (r rational) Ceil() rational {
+	return r.Minus().Floor().Minus()
+}
+
+// Round returns the integer nearest to r, rounding half away from zero.
+func (r rational) Round() rational {
+	if r.Negative() {
+		return r.Minus().Round().Minus()
+	}
+	r = r.normalize()
+	q, rem := r.n/r.d, r.n%r.d
+	if rem >= r.d-rem {
+		q++
+	}
+	return rational{q, 1}
+}
+
 func (r rational) isLess(r1 rational) bool {
-	x := r.n*r1.d < r.d*r1.n
+	a, ok1 := mul64(r.n, r1.d)
+	b, ok2 := mul64(r.d, r1.n)
+	if !ok1 || !ok2 {
+		return r.Value() < r1.Value()
+	}
+	x := a < b
 	if r.d*r1.d > 0 {
 		return x
 	} else {
@@ -244,7 +464,51 @@ func (r rational) MinusOne() bool {
 }
 
 func (r rational) Sqrt() (rational, error) {
-	return r.Pow(rational{1, 2})
+	s, err := r.Pow(rational{1, 2})
+	if e, ok := err.(*OpError); ok {
+		return rational{}, newOpError(e.Err, OpSqrt, r)
+	}
+	return s, err
+}
+
+// Root returns the r-th root of r1, that is r1 ^ (1/r).
+func (r rational) Root(r1 rational) (rational, error) {
+	if r.n == 0 {
+		return rational{}, newOpError(ErrDomain, OpRoot, r, r1)
+	}
+	s, err := r1.Pow(rational{r.d, r.n}.normalize())
+	if e, ok := err.(*OpError); ok {
+		return rational{}, newOpError(e.Err, OpRoot, r, r1)
+	}
+	return s, err
+}
+
+// Log returns the logarithm of r1 to base r, if it is rational.
+func (r rational) Log(r1 rational) (rational, error) {
+	r, r1 = r.normalize(), r1.normalize()
+	if r.n <= 0 || r1.n <= 0 || r.One() {
+		return rational{}, newOpError(ErrDomain, OpLog, r, r1)
+	} else if r1.One() {
+		return rational{0, 1}, nil
+	}
+	// r = c^k and r1 = c1^k1, where neither c nor c1 is a power of a rational,
+	// so the logarithm is rational only if c1 is c or 1/c.
+	c, k := r.perfectPower()
+	c1, k1 := r1.perfectPower()
+	if c1.isEqual(c) {
+		return rational{k1, k}.normalize(), nil
+	} else if c1.isEqual(rational{c.d, c.n}) {
+		return rational{-k1, k}.normalize(), nil
+	}
+	return rational{}, newOpError(ErrNotRational, OpLog, r, r1)
+}
+
+// perfectPower returns c and the largest k such that r = c^k, for positive r != 1.
+func (r rational) perfectPower() (rational, int64) {
+	n, kn := perfectPower(r.n)
+	d, kd := perfectPower(r.d)
+	k := gcd(kn, kd)
+	return rational{pow(n, kn/k), pow(d, kd/k)}, k
 }
 
 func (r rational) Negative() bool {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -78,6 +79,47 @@ func TestRationalOps(t *testing.T) {
 	}
 }
 
+func TestRationalLookupOps(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []testCase{
+		{"0", OpDoubleFact, "", "1"},
+		{"5", OpDoubleFact, "", "15"},
+		{"6", OpDoubleFact, "", "48"},
+		{"33", OpDoubleFact, "", "6332659870762850625"},
+		{"0", OpSubfact, "", "1"},
+		{"1", OpSubfact, "", "0"},
+		{"4", OpSubfact, "", "9"},
+		{"20", OpSubfact, "", "895014631192902121"},
+		{"1", OpPrimorial, "", "1"},
+		{"10", OpPrimorial, "", "210"},
+		{"52", OpPrimorial, "", "614889782588491410"},
+		{"4", OpRecip, "", "1/4"},
+		{"-2/3", OpRecip, "", "-3/2"},
+		{"-2/3", OpAbs, "", "2/3"},
+		{"5", OpAbs, "", "5"},
+	} {
+		v, err := rat(tc.a).PerformUnary(tc.op)
+		assert.NoError(err)
+		assert.Equal(rat(tc.r), v, "%s %s", tc.op, tc.a)
+	}
+	for _, tc := range []struct {
+		a   string
+		op  Op
+		err error
+	}{
+		{"34", OpDoubleFact, ErrOverflow},
+		{"-1", OpDoubleFact, ErrDomain},
+		{"21", OpSubfact, ErrOverflow},
+		{"1/2", OpSubfact, ErrDomain},
+		{"53", OpPrimorial, ErrOverflow},
+		{"-3", OpPrimorial, ErrDomain},
+		{"0", OpRecip, ErrDivByZero},
+	} {
+		_, err := rat(tc.a).PerformUnary(tc.op)
+		assert.True(errors.Is(err, tc.err), "%s %s: %v", tc.op, tc.a, err)
+	}
+}
+
 func rat(s string) rational {
 	r, _ := newRationalFromString(s)
 	return r
@@ -133,6 +175,9 @@ func TestRationalMisc(t *testing.T) {
 	assert.False(rat("1/3").Less(rat("1/-2")))
 	assert.False(rat("-1/3").Less(rat("1/-2")))
 	assert.False(rat("1/3").Less(rat("-2")))
+	// Cross products overflow int64 here
+	assert.True(rat("4294967291/4294967279").Less(rat("4294967311/4294967291")))
+	assert.False(rat("4294967311/4294967291").Less(rat("4294967291/4294967279")))
 
 	assert.True(rat("9").IsInteger())
 	assert.True(rat("-9/3").IsInteger())
@@ -153,3 +198,248 @@ func TestRationalMisc(t *testing.T) {
 	assert.True(rat("-3/6").Equal(rat("1/-2")))
 	assert.False(rat("-3/6").Equal(rat("-2")))
 }
+
+func TestRationalRounding(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []struct{ a, floor, ceil, round string }{
+		{"3", "3", "3", "3"},
+		{"7/2", "3", "4", "4"},
+		{"-7/2", "-4", "-3", "-4"},
+		{"10/3", "3", "4", "3"},
+		{"-10/3", "-4", "-3", "-3"},
+		{"5/3", "1", "2", "2"},
+		{"-1/3", "-1", "0", "0"},
+	} {
+		for op, r := range map[Op]string{OpFloor: tc.floor, OpCeil: tc.ceil, OpRound: tc.round} {
+			v, err := rat(tc.a).PerformUnary(op)
+			assert.NoError(err)
+			assert.Equal(rat(r), v, "%s %s", op, tc.a)
+		}
+	}
+}
+
+func TestRationalIntDiv(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []testCase{
+		{"7", OpIntDiv, "2", "3"},
+		{"7", OpMod, "2", "1"},
+		{"-7", OpIntDiv, "2", "-4"},
+		{"-7", OpMod, "2", "1"},
+		{"7", OpIntDiv, "-2", "-4"},
+		{"7", OpMod, "-2", "-1"},
+		{"6", OpMod, "3", "0"},
+	} {
+		v, err := rat(tc.a).PerformBinary(tc.op, rat(tc.b))
+		assert.NoError(err)
+		assert.Equal(rat(tc.r), v, "%s %s %s", tc.a, tc.op, tc.b)
+	}
+	_, err := rat("7/2").PerformBinary(OpIntDiv, rat("2"))
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = rat("7").PerformBinary(OpMod, rat("1/2"))
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = rat("7").PerformBinary(OpMod, rat("0"))
+	assert.True(errors.Is(err, ErrDivByZero))
+}
+
+func TestRationalCombinatorial(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []testCase{
+		{"5", OpChoose, "2", "10"},
+		{"5", OpChoose, "0", "1"},
+		{"5", OpChoose, "5", "1"},
+		{"62", OpChoose, "31", "465428353255261088"},
+		{"5", OpPerm, "2", "20"},
+		{"5", OpPerm, "0", "1"},
+		{"20", OpPerm, "20", "2432902008176640000"},
+	} {
+		v, err := rat(tc.a).PerformBinary(tc.op, rat(tc.b))
+		assert.NoError(err)
+		assert.Equal(rat(tc.r), v, "%s(%s, %s)", tc.op, tc.a, tc.b)
+	}
+	for _, tc := range []struct {
+		a, b string
+		op   Op
+		err  error
+	}{
+		{"2", "5", OpChoose, ErrDomain},
+		{"5", "-1", OpChoose, ErrDomain},
+		{"5/2", "1", OpPerm, ErrDomain},
+		{"68", "34", OpChoose, ErrOverflow},
+		{"21", "21", OpPerm, ErrOverflow},
+	} {
+		_, err := rat(tc.a).PerformBinary(tc.op, rat(tc.b))
+		assert.True(errors.Is(err, tc.err), "%s(%s, %s): %v", tc.op, tc.a, tc.b, err)
+	}
+}
+
+func TestRationalRoot(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []testCase{
+		{"3", OpRoot, "8", "2"},
+		{"3", OpRoot, "-27/8", "-3/2"},
+		{"2", OpRoot, "9", "3"},
+		{"-2", OpRoot, "9", "1/3"},
+		{"2/3", OpRoot, "4", "8"},
+	} {
+		v, err := rat(tc.a).PerformBinary(tc.op, rat(tc.b))
+		assert.NoError(err)
+		assert.Equal(rat(tc.r), v, "root(%s, %s)", tc.a, tc.b)
+	}
+	var e *OpError
+	_, err := rat("3").Root(rat("9"))
+	assert.True(errors.As(err, &e))
+	assert.Equal(OpRoot, e.Op)
+	assert.True(errors.Is(err, ErrNotRational))
+	_, err = rat("4").Root(rat("-16"))
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = rat("0").Root(rat("2"))
+	assert.True(errors.Is(err, ErrDomain))
+}
+
+func TestRationalLog(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []testCase{
+		{"2", OpLog, "8", "3"},
+		{"4", OpLog, "8", "3/2"},
+		{"8", OpLog, "4", "2/3"},
+		{"2", OpLog, "1/8", "-3"},
+		{"1/2", OpLog, "8", "-3"},
+		{"4/9", OpLog, "27/8", "-3/2"},
+		{"7", OpLog, "1", "0"},
+		{"3", OpLog, "4052555153018976267", "39"},
+		{"1/16", OpLog, "1/8", "3/4"},
+	} {
+		v, err := rat(tc.a).PerformBinary(tc.op, rat(tc.b))
+		assert.NoError(err)
+		assert.Equal(rat(tc.r), v, "log_%s(%s)", tc.a, tc.b)
+	}
+	for _, tc := range []struct {
+		a, b string
+		err  error
+	}{
+		{"2", "6", ErrNotRational},
+		{"4", "2/3", ErrNotRational},
+		{"12", "18", ErrNotRational},
+		{"1", "8", ErrDomain},
+		{"-2", "4", ErrDomain},
+		{"2", "0", ErrDomain},
+	} {
+		_, err := rat(tc.a).Log(rat(tc.b))
+		assert.True(errors.Is(err, tc.err), "log_%s(%s): %v", tc.a, tc.b, err)
+	}
+}
+
+func TestRationalErrorKinds(t *testing.T) {
+	assert := assert.New(t)
+	_, err := rat("1").Div(rat("0"))
+	assert.True(errors.Is(err, ErrDivByZero))
+	_, err = rat("0").Pow(rat("-1"))
+	assert.True(errors.Is(err, ErrDivByZero))
+	_, err = rat("0").Pow(rat("0"))
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = rat("30").Pow(rat("14"))
+	assert.True(errors.Is(err, ErrOverflow))
+	_, err = rat("21").Fact()
+	assert.True(errors.Is(err, ErrOverflow))
+	_, err = rat("2").Pow(rat("63"))
+	assert.True(errors.Is(err, ErrOverflow))
+	_, err = rat("6402373705728000").Mul(rat("2432902008176640000"))
+	assert.True(errors.Is(err, ErrOverflow))
+	_, err = rat("9223372036854775807").Add(rat("1"))
+	assert.True(errors.Is(err, ErrOverflow))
+	_, err = rat("1/4294967311").Sub(rat("1/4294967291"))
+	assert.True(errors.Is(err, ErrOverflow))
+	r, err := rat("3").Pow(rat("39"))
+	assert.NoError(err)
+	assert.Equal(rat("4052555153018976267"), r)
+	r, err = rat("6402373705728000/7").Div(rat("6402373705728000/14"))
+	assert.NoError(err)
+	assert.Equal(rat("2"), r)
+	_, err = rat("1/2").Fact()
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = rat("-4").Sqrt()
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = rat("5").Sqrt()
+	assert.True(errors.Is(err, ErrNotRational))
+	_, err = newRational(3, 0)
+	assert.True(errors.Is(err, ErrDivByZero))
+	_, err = rat("2").PerformBinary(OpAdd, ratSurd(rat("2")))
+	assert.True(errors.Is(err, ErrMismatch))
+	assert.False(errors.Is(err, ErrNotRational))
+
+	var e *OpError
+	_, err = rat("2").Pow(rat("1/2"))
+	assert.True(errors.As(err, &e))
+	assert.Equal(OpPow, e.Op)
+	assert.Equal([]Value{rat("2"), rat("1/2")}, e.Args)
+
+	_, err = (&Node{op: OpAdd, right: newIntNode(1)}).Eval()
+	assert.True(errors.Is(err, ErrInvalidNode))
+	n, _ := FromPolish("+ 1 / 2 0")
+	_, err = n.Eval()
+	assert.True(errors.Is(err, ErrDivByZero))
+	_, err = n.Trace()
+	assert.True(errors.Is(err, ErrDivByZero))
+}
+
+func TestRationalFromDecimal(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []struct{ s, r string }{
+		{".5", "1/2"},
+		{"0.5", "1/2"},
+		{"1.25", "5/4"},
+		{"-.25", "-1/4"},
+		{".(3)", "1/3"},
+		{".(9)", "1"},
+		{"1.(3)", "4/3"},
+		{".1(6)", "1/6"},
+		{"2.", "2"},
+	} {
+		r, err := newRationalFromString(tc.s)
+		assert.NoError(err, tc.s)
+		assert.Equal(rat(tc.r), r, tc.s)
+	}
+	for _, s := range []string{".", "1.2.3", ".()", ".(3", "1.2(3)4", "1.2345678901"} {
+		_, err := newRationalFromString(s)
+		assert.Error(err, s)
+	}
+}
+
+// withBase runs f with numbers in base b.
+func withBase(b int, f func()) {
+	defer setBase(numBase)
+	if err := setBase(b); err != nil {
+		panic(err)
+	}
+	f()
+}
+
+func TestRationalBase(t *testing.T) {
+	assert := assert.New(t)
+	assert.Error(setBase(1))
+	assert.Error(setBase(37))
+	withBase(16, func() {
+		for _, tc := range []struct {
+			s    string
+			n, d int64
+		}{
+			{"ff", 255, 1},
+			{"-a/c", -5, 6},
+			{".8", 1, 2},
+			{".(5)", 1, 3},
+			{"1.(f)", 2, 1},
+		} {
+			r, err := newRationalFromString(tc.s)
+			assert.NoError(err, tc.s)
+			assert.Equal(rational{tc.n, tc.d}, r, tc.s)
+		}
+		assert.Equal("-5/6", rational{-5, 6}.String())
+		assert.Equal("ff", rational{255, 1}.String())
+	})
+	withBase(2, func() {
+		assert.Equal("1/11", rational{1, 3}.String())
+		_, err := newRationalFromString("12")
+		assert.Error(err)
+	})
+	assert.Equal("255", rational{255, 1}.String())
+}
@@ -0,0 +1,177 @@
+// This file contains residues modulo m, used with --mod m.
+package main
+
+import "fmt"
+
+// residue stores n mod modulus as its least nonnegative representative 0 <= n < modulus.
+// Arithmetic is modular, division multiplies by the modular inverse and powers use modular
+// exponentiation. Operators on integers, like factorial, exponents of powers or C, apply to
+// the representatives, so equal residues always give equal results. Square roots, which
+// are ambiguous mod m, and absolute values are not defined, and residues are never
+// negative.
+type residue struct {
+	n int64
+}
+
+// newResidue converts r to a residue, and fails if its denominator is not coprime with
+// modulus.
+func newResidue(r rational) (residue, error) {
+	r = r.normalize()
+	n := r.n % modulus
+	if n < 0 {
+		n += modulus
+	}
+	d, ok := invMod(r.d%modulus, modulus)
+	if !ok {
+		return residue{}, fmt.Errorf("%w: denominator %s of %s has no inverse modulo %s",
+			ErrDomain, formatInt(r.d), r, formatInt(modulus))
+	}
+	return residue{mulMod(n, d, modulus)}, nil
+}
+
+// toResidue converts residues and rationals to residue.
+func toResidue(v Value) (residue, bool) {
+	switch v := v.(type) {
+	case residue:
+		return v, true
+	case rational:
+		r, err := newResidue(v)
+		return r, err == nil
+	}
+	return residue{}, false
+}
+
+// newResidueFromString is an opposite of residue.String.
+func newResidueFromString(s string) (residue, error) {
+	r, err := newRationalFromString(s)
+	if err != nil {
+		return residue{}, err
+	}
+	return newResidue(r)
+}
+
+func (x residue) String() string {
+	return formatInt(x.n)
+}
+
+// rational returns the least nonnegative representative of x.
+func (x residue) rational() rational {
+	return rational{x.n, 1}
+}
+
+// maxResidueFact limits factorials, which are calculated by multiplication until the
+// product is 0.
+const maxResidueFact = 1000
+
+// PerformUnary is an implementation of Value.PerformUnary.
+func (x residue) PerformUnary(op Op) (Value, error) {
+	switch op {
+	case OpMinus:
+		return residue{(modulus - x.n) % modulus}, nil
+	case OpRecip:
+		return residue{1 % modulus}.div(x, op)
+	case OpSqrt, OpAbs:
+		return residue{}, newOpError(ErrDomain, op, x)
+	case OpFact:
+		return x.fact()
+	}
+	v, err := x.rational().PerformUnary(op)
+	if err == nil {
+		v, err = newResidue(v.(rational))
+	}
+	if err != nil {
+		return residue{}, rewrap(err, op, x)
+	}
+	return v, nil
+}
+
+// fact returns x! mod modulus.
+func (x residue) fact() (residue, error) {
+	f := 1 % modulus
+	for i := int64(2); i <= x.n && f != 0; i++ {
+		if i > maxResidueFact {
+			return residue{}, newOpError(ErrOverflow, OpFact, x)
+		}
+		f = mulMod(f, i, modulus)
+	}
+	return residue{f}, nil
+}
+
+// PerformBinary is an implementation of Value.PerformBinary.
+func (x residue) PerformBinary(op Op, v Value) (Value, error) {
+	y, ok := toResidue(v)
+	if !ok {
+		return residue{}, newOpError(ErrMismatch, op, x, v)
+	}
+	switch op {
+	case OpAdd:
+		return residue{int64((uint64(x.n) + uint64(y.n)) % uint64(modulus))}, nil
+	case OpSub:
+		return residue{int64((uint64(x.n) + uint64(modulus-y.n)) % uint64(modulus))}, nil
+	case OpMul:
+		return residue{mulMod(x.n, y.n, modulus)}, nil
+	case OpDiv:
+		return x.div(y, op)
+	case OpPow:
+		if x.n == 0 && y.n == 0 {
+			return residue{}, newOpError(ErrDomain, op, x, y)
+		}
+		return residue{powMod(x.n, y.n, modulus)}, nil
+	}
+	v, err := x.rational().PerformBinary(op, y.rational())
+	if err == nil {
+		v, err = newResidue(v.(rational))
+	}
+	if err != nil {
+		return residue{}, rewrap(err, op, x, y)
+	}
+	return v, nil
+}
+
+// div returns x / y, or an error of op if y has no inverse.
+func (x residue) div(y residue, op Op) (residue, error) {
+	if y.n == 0 {
+		return residue{}, newOpError(ErrDivByZero, op, x, y)
+	}
+	inv, ok := invMod(y.n, modulus)
+	if !ok {
+		return residue{}, newOpError(ErrDomain, op, x, y)
+	}
+	return residue{mulMod(x.n, inv, modulus)}, nil
+}
+
+// Equal is an implementation of Value.Equal
+func (x residue) Equal(v Value) bool {
+	y, ok := toResidue(v)
+	return ok && x == y
+}
+
+// Less is an implementation of Value.Less, comparing the representatives.
+func (x residue) Less(v Value) bool {
+	y, ok := toResidue(v)
+	return ok && x.n < y.n
+}
+
+func (x residue) IsInteger() bool {
+	return true
+}
+
+func (x residue) Negative() bool {
+	return false
+}
+
+func (x residue) Even() bool {
+	return x.n%2 == 0
+}
+
+func (x residue) Zero() bool {
+	return x.n == 0
+}
+
+func (x residue) One() bool {
+	return x.n == 1
+}
+
+func (x residue) MinusOne() bool {
+	return x.n == modulus-1
+}
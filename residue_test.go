@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withModulus runs f with residues modulo m.
+func withModulus(m int64, f func()) {
+	defer func(m int64) { modulus = m }(modulus)
+	modulus = m
+	f()
+}
+
+// res returns a residue for a rational written as s.
+func res(s string) residue {
+	r, err := newResidue(rat(s))
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestResidueOps(t *testing.T) {
+	assert := assert.New(t)
+	withModulus(7, func() {
+		for _, tc := range []testCase{
+			{"5", OpAdd, "4", "2"},
+			{"2", OpSub, "5", "4"},
+			{"3", OpMul, "5", "1"},
+			{"1", OpDiv, "3", "5"},
+			{"3", OpPow, "6", "1"},
+			{"3", OpPow, "13", "1"},
+			{"2", OpPow, "7", "1"},
+			{"2", OpPow, "8", "2"},
+			{"2", OpPow, "-1", "1"},
+			{"10", OpPow, "2", "2"},
+			{"10", OpIntDiv, "2", "1"},
+			{"5", OpChoose, "2", "3"},
+		} {
+			v, err := res(tc.a).PerformBinary(tc.op, res(tc.b))
+			assert.NoError(err)
+			assert.Equal(res(tc.r), v, "%s %s %s", tc.a, tc.op, tc.b)
+		}
+		for _, tc := range []struct {
+			a  string
+			op Op
+			r  string
+		}{
+			{"3", OpMinus, "4"},
+			{"0", OpMinus, "0"},
+			{"3", OpRecip, "5"},
+			{"3", OpFact, "6"},
+			{"6", OpFact, "6"},
+			{"8", OpFact, "1"},
+			{"13", OpFact, "6"},
+			{"1/2", OpFloor, "4"},
+		} {
+			v, err := res(tc.a).PerformUnary(tc.op)
+			assert.NoError(err)
+			assert.Equal(res(tc.r), v, "%s %s", tc.op, tc.a)
+		}
+		assert.Equal(res("4"), res("-3"))
+		assert.Equal(res("4"), res("1/2"))
+
+		_, err := res("3").PerformBinary(OpDiv, res("7"))
+		assert.True(errors.Is(err, ErrDivByZero))
+		_, err = res("0").PerformBinary(OpPow, res("7"))
+		assert.True(errors.Is(err, ErrDomain))
+		_, err = res("4").PerformUnary(OpSqrt)
+		assert.True(errors.Is(err, ErrDomain))
+		_, err = newResidue(rat("1/14"))
+		assert.True(errors.Is(err, ErrDomain))
+		assert.Contains(err.Error(), "denominator 14 of 1/14 has no inverse modulo 7")
+	})
+	withModulus(10, func() {
+		_, err := res("3").PerformBinary(OpDiv, res("4"))
+		assert.True(errors.Is(err, ErrDomain))
+		v, err := res("3").PerformBinary(OpDiv, res("7"))
+		assert.NoError(err)
+		assert.Equal(res("9"), v)
+		v, err = res("3").PerformBinary(OpPow, res("3"))
+		assert.NoError(err)
+		assert.Equal(res("7"), v)
+		v, err = res("9").PerformUnary(OpFact)
+		assert.NoError(err)
+		assert.Equal(res("0"), v)
+	})
+	withModulus(1<<62+1, func() {
+		v, err := res("-1").PerformBinary(OpMul, res("-1"))
+		assert.NoError(err)
+		assert.Equal(res("1"), v)
+		v, err = res("-1").PerformBinary(OpAdd, res("-1"))
+		assert.NoError(err)
+		assert.Equal(res("-2"), v)
+		_, err = res("2000").PerformUnary(OpFact)
+		assert.True(errors.Is(err, ErrOverflow))
+	})
+}
+
+func TestResidueMisc(t *testing.T) {
+	assert := assert.New(t)
+	withModulus(7, func() {
+		assert.Equal("4", res("11").String())
+		assert.True(res("6").MinusOne())
+		assert.True(res("8").One())
+		assert.False(res("-1").Negative())
+		assert.True(res("2").Less(res("3")))
+		assert.True(res("10").Equal(rat("3")))
+		assert.True(inRange(res("10"), 0, 6))
+		assert.False(inRange(res("10"), 4, 6))
+
+		// Numbers written with more than the modulus are printed as written
+		n, err := FromPolish("+ 12 3")
+		assert.NoError(err)
+		assert.Equal("12 + 3", n.String())
+		assert.Equal("+ 12 3", n.ToPolish())
+
+		_, err = FromPolish("+ 1/7 1")
+		assert.Contains(err.Error(), "denominator 7 of 1/7 has no inverse modulo 7")
+	})
+}
+
+func TestSearchResidues(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 2
+	withModulus(7, func() {
+		p, _ := Search("123")
+		found := make(map[string]bool)
+		for _, n := range p.Formulas(res("0")) {
+			found[n.String()] = true
+			v, err := n.Eval()
+			assert.NoError(err)
+			assert.True(v.Equal(res("0")))
+		}
+		assert.True(found["1 - (2 ^ 3)"])
+		assert.True(found["1 / 2 + 3"])
+
+		for polish, r := range map[string]string{"^ 2 7": "1", "^ 2 8": "2", "! 8": "1", "! 6": "6"} {
+			n, err := FromPolish(polish)
+			assert.NoError(err)
+			v, err := n.Eval()
+			assert.NoError(err)
+			assert.Equal(res(r), v, polish)
+		}
+		// Operators on integers give the same results for equal residues
+		for polish, literal := range map[string]string{"^ 2 + 3 4": "^ 2 7", "! + 3 4": "! 7", "C + 3 4 2": "C 7 2",
+			"! + 1 2": "! 3", "!! * 2 3": "!! 6", "P 5 - 3 1": "P 5 2", "div 7 + 1 1": "div 7 2",
+			"mod 7 + 1 1": "mod 7 2", "root + 1 1 4": "root 2 4", "log 2 * 2 2": "log 2 4"} {
+			n, err := FromPolish(polish)
+			assert.NoError(err)
+			v, err := n.Eval()
+			n, err1 := FromPolish(literal)
+			assert.NoError(err1)
+			r, err1 := n.Eval()
+			if assert.Equal(err == nil, err1 == nil, polish) && err == nil {
+				assert.Equal(r, v, polish)
+			}
+		}
+
+		for _, v := range []Value{res("5"), res("3"), res("12")} {
+			s := Solution{val: v, start: 0, end: 2}
+			s1, err := newCacheEntry(s).solution()
+			assert.NoError(err)
+			assert.Equal(s, s1)
+		}
+
+		// The number as written and the calculated residue are the same solution
+		p, _ = Search("12")
+		found = make(map[string]bool)
+		for _, n := range p.Formulas(res("5")) {
+			found[n.String()] = true
+		}
+		assert.Equal(map[string]bool{"12": true, "-(1 * 2)": true}, found)
+	})
+}
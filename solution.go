@@ -1,16 +1,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// start and end indicates that we used digits[start:end] for this formula,
-// where digits is the original digits string. We cannot use binary operator for s1, s2
-// if s1.end != s2.start
+// start and end indicates that we used tokens[start:end] for this formula, where tokens
+// are the original digits string split by splitTokens. We cannot use binary operator for
+// s1, s2 if s1.end != s2.start
 type Solution struct {
 	val        Value
 	start, end int
@@ -23,18 +25,99 @@ func init() {
 }
 
 // Global variables are bad for you health
-var solutions map[Solution][]*Node // solutions found so far
-var maxDepth int64                 // If positive, only search for formulas of up to this level. If zero, only stores the first solution.
+var solutions map[Solution][]*Node      // solutions found so far
+var stored int                          // number of formulas in solutions
+var completed map[Range]SolutionSlice   // results of FindAllSolutions for completed ranges
+var inProgress map[Range]*rangeProgress // state of FindAllSolutions for ranges not completed yet
+var maxDepth int64                      // If positive, only search for formulas of up to this level. If zero, only stores the first solution.
+var decimals bool                       // If true, digits can also be used as decimals, like .5 or 1.(3)
+var surds bool                          // If true, values are quadratic surds, so irrational square roots are kept
+var complexValues bool                  // If true, values are complex rationals, so square roots of negatives are kept
+var intervals bool                      // If true, values are float intervals, so inexact values are kept
+var modulus int64                       // If positive, values are residues modulo it
+var latexOutput bool                    // If true, Print writes formulas in LaTeX
 
 func init() {
+	resetSolutions()
+}
+
+// resetSolutions forgets all solutions found so far.
+func resetSolutions() {
 	solutions = make(map[Solution][]*Node)
+	stored = 0
+	completed = make(map[Range]SolutionSlice)
+	inProgress = make(map[Range]*rangeProgress)
+}
+
+// rangeProgress is the state of FindAllSolutions for a range which is not completed yet:
+// results of all splits of its tokens before split, and of the first pairs pairs of
+// solutions for split. It allows to continue a stopped search where it left.
+type rangeProgress struct {
+	split, pairs int
+	results      SolutionSlice
+}
+
+// binaryOps lists binary operators tried by the search.
+var binaryOps = []Op{OpAdd, OpSub, OpMul, OpDiv, OpPow}
+
+// unaryOps lists unary operators tried by the search; see AllUnary for how they are applied.
+var unaryOps = []Op{OpMinus, OpFact, OpSqrt}
+
+// extraUnaryOps lists optional unary operators enabled for the search. Unlike unaryOps,
+// they are applied only once in AllUnary.
+var extraUnaryOps []Op
+
+// enableOps enables optional operators for the search, given as a comma-separated list
+// of their names in Polish notation, e.g. "!!,subfact,#".
+func enableOps(names string) error {
+	for _, name := range strings.Split(names, ",") {
+		op, ok := opByName(strings.TrimSpace(name))
+		if !ok {
+			return fmt.Errorf("unknown operator '%s'", name)
+		}
+		if op.binary() && !hasOp(binaryOps, op) {
+			binaryOps = append(binaryOps, op)
+		} else if op.unary() && !hasOp(unaryOps, op) && !hasOp(extraUnaryOps, op) {
+			extraUnaryOps = append(extraUnaryOps, op)
+		}
+	}
+	return nil
+}
+
+// opsKey returns a string identifying operators used by the search.
+func opsKey() string {
+	// A fresh slice, as appending to binaryOps could overwrite its spare capacity
+	ops := make([]Op, 0, len(binaryOps)+len(unaryOps)+len(extraUnaryOps))
+	ops = append(append(append(ops, binaryOps...), unaryOps...), extraUnaryOps...)
+	var names []string
+	for _, op := range ops {
+		names = append(names, op.String())
+	}
+	if decimals {
+		names = append(names, ".")
+	}
+	if surds {
+		names = append(names, "surd")
+	}
+	if complexValues {
+		names = append(names, "complex")
+	}
+	if intervals {
+		names = append(names, "interval")
+	}
+	if modulus > 0 {
+		names = append(names, fmt.Sprintf("mod %d", modulus))
+	}
+	if numBase != 10 {
+		names = append(names, fmt.Sprintf("base %d", numBase))
+	}
+	return strings.Join(names, " ")
 }
 
 // Add adds a new formula for s, but only if it's unique and has reasonable depth.
 // If v == nil, seed solutions with initial digits.
 func (s Solution) Add(v *Node) {
 	if maxDepth == 0 && solutions[s] != nil {
-
 		return
 	}
 	if v == nil {
@@ -43,14 +126,21 @@ func (s Solution) Add(v *Node) {
 		v = v.Simplify()
 	}
 	if maxDepth != 0 && v.Depth() > maxDepth && solutions[s] != nil {
+		stats.TooDeep++
 		return
 	}
 	for _, v1 := range solutions[s] {
 		if v.Equal(v1) {
+			stats.Duplicates++
 			return
 		}
 	}
 	solutions[s] = append(solutions[s], v)
+	stored++
+	stats.Added++
+	if stored > stats.Peak {
+		stats.Peak = stored
+	}
 }
 
 // Apply an unary operator to this solution, if possible, and add to all solutions
@@ -60,7 +150,8 @@ func (s Solution) Unary(op Op) Solution {
 		return s
 	}
 	v1, err := s.val.PerformUnary(op)
-	if err != nil {
+	stats.tried(op, s.start, s.end, err)
+	if err != nil || v1.Equal(s.val) {
 		return NoSolution
 	}
 	s1 := Solution{val: v1, start: s.start, end: s.end}
@@ -80,6 +171,7 @@ func (s1 Solution) Binary(op Op, s2 Solution) Solution {
 		return NoSolution
 	}
 	v1, err := s1.val.PerformBinary(op, s2.val)
+	stats.tried(op, s1.start, s2.end, err)
 	if err != nil {
 		return NoSolution
 	}
@@ -106,7 +198,14 @@ func (p SolutionSlice) Len() int {
 	return len(p)
 }
 
+// Less orders values by Value.Less, and puts values which are not ordered by it last,
+// sorted by String.
 func (p SolutionSlice) Less(i, j int) bool {
+	if o1, o2 := ordered(p[i].val), ordered(p[j].val); o1 != o2 {
+		return o1
+	} else if !o1 {
+		return p[i].val.String() < p[j].val.String()
+	}
 	return p[i].val.Less(p[j].val)
 }
 
@@ -118,6 +217,11 @@ func (p SolutionSlice) Sort() {
 	sort.Sort(p)
 }
 
+// maxUnaryChain limits the number of factorials or square roots applied in a row by
+// AllUnary. Exact values never get that far, but inexact ones have square roots forever,
+// so FindApproximations lowers it further.
+var maxUnaryChain = 6
+
 // AllUnary generates all possible solutions we can get from s using unary operations,
 // including itself (= no operation was applied).
 func (s Solution) AllUnary() SolutionSlice {
@@ -134,31 +238,55 @@ func (s Solution) AllUnary() SolutionSlice {
 		result = append(result, s1)
 	}
 	if s.val.Negative() {
+		if complexValues {
+			// Only complex values have square roots of negatives
+			if sq := s.Unary(OpSqrt); sq != NoSolution {
+				result = append(result, sq)
+			}
+		}
 		if s1 == NoSolution {
 			return result
 		} else {
 			s = s1
 		}
 	}
-	for f := s.Unary(OpFact); f != NoSolution; f = f.Unary(OpFact) {
+	for f, i := s.Unary(OpFact), 0; f != NoSolution && i < maxUnaryChain; f, i = f.Unary(OpFact), i+1 {
 		result = append(result, f)
 		result = append(result, f.Unary(OpMinus))
 	}
-	for sq := s.Unary(OpSqrt); sq != NoSolution; sq = sq.Unary(OpSqrt) {
+	for sq, i := s.Unary(OpSqrt), 0; sq != NoSolution && i < maxUnaryChain; sq, i = sq.Unary(OpSqrt), i+1 {
 		result = append(result, sq)
-		for f := sq.Unary(OpFact); f != NoSolution; f = f.Unary(OpFact) {
+		for f, j := sq.Unary(OpFact), 0; f != NoSolution && j < maxUnaryChain; f, j = f.Unary(OpFact), j+1 {
 			result = append(result, f)
 		}
 	}
+	for _, op := range extraUnaryOps {
+		if e := s.Unary(op); e != NoSolution {
+			result = append(result, e)
+			if m := e.Unary(OpMinus); m != NoSolution {
+				result = append(result, m)
+			}
+		}
+	}
+	return uniq(result)
+}
+
+// AllUnary applies AllUnary to every solution in p, and returns unique results.
+func (p SolutionSlice) AllUnary() SolutionSlice {
+	var result SolutionSlice
+	for _, s := range p {
+		result = append(result, s.AllUnary()...)
+	}
 	return uniq(result)
 }
 
 // AllBinary generates all possible binary solutions for s1 and s2.
 func (s1 Solution) AllBinary(s2 Solution) SolutionSlice {
 	result := SolutionSlice{}
-	for op := OpAdd; op <= OpPow; op++ {
-		for _, s3 := range s1.AllUnary() {
-			for _, s4 := range s2.AllUnary() {
+	u1, u2 := s1.AllUnary(), s2.AllUnary()
+	for _, op := range binaryOps {
+		for _, s3 := range u1 {
+			for _, s4 := range u2 {
 				if s5 := s3.Binary(op, s4); s5 != NoSolution {
 					result = append(result, s5)
 				}
@@ -171,24 +299,100 @@ func (s1 Solution) AllBinary(s2 Solution) SolutionSlice {
 // uniq returns only unique solutions from the list
 func uniq(l SolutionSlice) SolutionSlice {
 	m := make(map[Solution]bool)
-	for _, n := range l {
-		m[n] = true
-	}
 	var result SolutionSlice
-	for k := range m {
-		result = append(result, k)
+	for _, n := range l {
+		// Keep the order, so that the search doesn't depend on the order of the map
+		if !m[n] {
+			m[n] = true
+			result = append(result, n)
+		}
 	}
 	return result
 }
 
-func atos(a string, start, end int) Solution {
-	n, err := strconv.Atoi(a)
+// atos returns solutions for a number written with digits a, which are tokens[start:end]
+// of the original digits: the integer itself and, if decimals is true, all decimals which
+// can be written with a. It fails if a is not a number which fits int64.
+func atos(a string, start, end int) (SolutionSlice, error) {
+	n, err := parseInt(a)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s to number: %w", a, err)
+	}
+	v, err := leafValue(rational{n, 1})
 	if err != nil {
-		log.Fatalf("Cannot convert %s to number\n", a)
+		return nil, fmt.Errorf("cannot convert %s to number: %w", a, err)
+	}
+	s := Solution{val: v, start: start, end: end}
+	if lit := formatInt(n); modulus > 0 && v.String() != lit {
+		// Keep the number as written, not its residue
+		s.Add(&Node{val: v, lit: lit})
+	} else {
+		s.Add(nil)
+	}
+	result := SolutionSlice{s}
+	if decimals && len(a) <= maxDecimalDigits {
+		for _, lit := range decimalLiterals(a) {
+			n, err := newLitNode(lit)
+			if errors.Is(err, ErrDomain) {
+				// Residues only have decimals with denominators coprime with the modulus
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("cannot convert %s to number: %w", lit, err)
+			}
+			s := Solution{val: n.val, start: start, end: end}
+			s.Add(n)
+			result = append(result, s)
+		}
+	}
+	return uniq(result), nil
+}
+
+// checkNumbers returns an error if tokens of digits (see splitTokens) make numbers which
+// don't fit int64: separated tokens are numbers by themselves, and other tokens are also
+// written together.
+func checkNumbers(tokens []string, separated bool) error {
+	if !separated {
+		tokens = []string{strings.Join(tokens, "")}
+	}
+	for _, t := range tokens {
+		if _, err := parseInt(t); err != nil {
+			return fmt.Errorf("cannot convert %s to number: %w", t, errors.Unwrap(err))
+		}
+	}
+	return nil
+}
+
+// decimalLiterals returns all decimals which can be written with digits a by placing
+// a decimal point, and optionally parenthesis around the repeating part: for "12" these
+// are .12, .1(2), .(12), 1.2 and 1.(2). Decimals with needless zeros or nines (the largest
+// digit of the base) are skipped.
+func decimalLiterals(a string) []string {
+	var result []string
+	nine := formatInt(int64(numBase - 1))
+	for p := 0; p < len(a); p++ {
+		if p > 1 && a[0] == '0' {
+			break
+		}
+		if a[len(a)-1] != '0' {
+			result = append(result, a[:p]+"."+a[p:])
+		}
+		for q := p; q < len(a); q++ {
+			if rep := strings.Trim(a[q:], "0"); rep != "" && strings.Trim(a[q:], nine) != "" {
+				result = append(result, a[:p]+"."+a[p:q]+"("+a[q:]+")")
+			}
+		}
+	}
+	return result
+}
+
+// isDigits returns true if s is a non-empty string of digits in the base numBase.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if !isBaseDigit(c) {
+			return false
+		}
 	}
-	s := Solution{val: rational{int64(n), 1}, start: start, end: start + len(a)}
-	s.Add(nil)
-	return s
+	return s != ""
 }
 
 func atoi(s string) int64 {
@@ -199,11 +403,88 @@ func atoi(s string) int64 {
 	return int64(n)
 }
 
-// min > max is a special case - to print all numbers
+// atoiBase is atoi for numbers written in the base numBase, like min and max.
+func atoiBase(s string) int64 {
+	n, err := parseInt(s)
+	if err != nil {
+		log.Fatalf("Cannot convert %s to number\n", s)
+	}
+	return n
+}
+
+// leafValue converts a number written with digits to the Value used for it by the search.
+// Only residues may fail, if the denominator of r has no inverse.
+func leafValue(r rational) (Value, error) {
+	if surds {
+		return ratSurd(r), nil
+	} else if complexValues {
+		return ratCplx(r), nil
+	} else if intervals {
+		return exactInterval(r), nil
+	} else if modulus > 0 {
+		return newResidue(r)
+	}
+	return r, nil
+}
+
+// parseValue is an opposite of Value.String for values used by the search.
+func parseValue(s string) (Value, error) {
+	if complexValues {
+		return newCplxFromString(s)
+	} else if intervals {
+		return newIntervalFromString(s)
+	} else if modulus > 0 {
+		return newResidueFromString(s)
+	}
+	n, err := FromInfix(s)
+	if err != nil {
+		return nil, err
+	}
+	return n.Eval()
+}
+
+// toRational returns v as rational, and false if v is not rational.
+func toRational(v Value) (rational, bool) {
+	switch v := v.(type) {
+	case rational:
+		return v, true
+	case surd:
+		return v.rational()
+	case cplx:
+		return v.real()
+	case interval:
+		return v.rational()
+	case residue:
+		return v.rational(), true
+	}
+	return rational{}, false
+}
+
+// ordered returns false for values which Value.Less doesn't order, which are complex
+// values with an imaginary part.
+func ordered(v Value) bool {
+	if z, ok := v.(cplx); ok {
+		_, ok = z.real()
+		return ok
+	}
+	return true
+}
+
+// inRange returns true for integer v in [min..max]. min > max is a special case - any v will do.
+// Values which are not rational are never in range.
+func inRange(v Value, min, max int64) bool {
+	r, ok := toRational(v)
+	if !ok {
+		return false
+	}
+	return !(min <= max && !r.IsInteger() || (r.Less(rational{min, 1}) || rational{max, 1}.Less(r)))
+}
+
+// min > max is a special case - to print all numbers. Values without formulas are skipped.
 func (p SolutionSlice) Print(all bool, min, max int64) {
 	p.Sort()
 	for _, f := range p {
-		if min <= max && !f.val.IsInteger() || (f.val.Less(rational{min, 1}) || rational{max, 1}.Less(f.val)) {
+		if !inRange(f.val, min, max) || len(solutions[f]) == 0 {
 			continue
 		}
 		if all {
@@ -213,24 +494,107 @@ func (p SolutionSlice) Print(all bool, min, max int64) {
 		}
 		answer := []string{}
 		for _, n := range solutions[f] {
-			answer = append(answer, fmt.Sprintf("[%2d] %s", n.Depth(), n))
+			s := n.String()
+			if latexOutput {
+				s = n.LaTeX()
+			}
+			answer = append(answer, fmt.Sprintf("[%2d] %s", n.Depth(), s))
 		}
 		sort.Strings(answer)
 		fmt.Printf("%s\n", strings.Join(answer, "\n"))
 	}
 }
 
+// Formulas returns all formulas found for v among the solutions in p.
+func (p SolutionSlice) Formulas(v Value) []*Node {
+	var result []*Node
+	for _, s := range p {
+		if s.val.Equal(v) {
+			result = append(result, solutions[s]...)
+		}
+	}
+	return result
+}
+
+// Search finds all values which can be made of digits, including unary operations
+// applied to the whole formula, and returns them together with statistics of the search.
+func Search(digits string) (SolutionSlice, *Stats) {
+	resetSolutions()
+	return search(digits)
+}
+
+// search does the actual work for Search, reusing ranges completed so far.
+func search(digits string) (SolutionSlice, *Stats) {
+	stats = newStats()
+	t := time.Now()
+	p := FindAllSolutions(digits, 0).AllUnary()
+	stats.Elapsed = time.Since(t)
+	return p, stats
+}
+
+// FindAllSolutions finds all values which can be made of digits, which start at token
+// start of the original digits. Results for every range are computed only once, and each
+// completed range is reported to the checkpointer, if any. If the checkpointer stops the
+// search, it returns nil, and the next call continues from the same place.
 func FindAllSolutions(digits string, start int) SolutionSlice {
-	if len(digits) == 0 {
+	tokens, separated := splitTokens(digits)
+	return findAllSolutions(tokens, separated, start)
+}
+
+// splitTokens splits digits into tokens, the leaves of formulas. Numbers separated by
+// spaces or commas, like "25 50 7" or "25,50,7", are tokens which are never concatenated,
+// and separated is true for them. Otherwise every digit is a token, and contiguous digits
+// can be concatenated into numbers.
+func splitTokens(digits string) (tokens []string, separated bool) {
+	if !strings.ContainsAny(digits, " ,") {
+		return strings.Split(digits, ""), false
+	}
+	return strings.FieldsFunc(digits, isSeparator), true
+}
+
+// isSeparator returns true for characters separating numbers in digits (see splitTokens).
+func isSeparator(c rune) bool {
+	return c == ' ' || c == ','
+}
+
+// findAllSolutions does the actual work for FindAllSolutions.
+func findAllSolutions(tokens []string, separated bool, start int) SolutionSlice {
+	if len(tokens) == 0 || checkpointer.stopped() {
 		return nil
 	}
-	r := atos(digits, start, start+len(digits)).AllUnary()
-	for i := 1; i < len(digits); i++ {
-		for _, s1 := range FindAllSolutions(digits[:i], start) {
-			for _, s2 := range FindAllSolutions(digits[i:], start+i) {
-				r = append(r, s1.AllBinary(s2)...)
+	rng := Range{start, start + len(tokens)}
+	if r, ok := completed[rng]; ok {
+		return r
+	}
+	pr := inProgress[rng]
+	if pr == nil {
+		pr = &rangeProgress{split: 1}
+		if len(tokens) == 1 || !separated {
+			// Numbers which don't fit int64 are skipped, see checkNumbers
+			if p, err := atos(strings.Join(tokens, ""), rng.Start, rng.End); err == nil {
+				pr.results = p.AllUnary()
+			}
+		}
+		inProgress[rng] = pr
+	}
+	for ; pr.split < len(tokens); pr.split, pr.pairs = pr.split+1, 0 {
+		p1 := findAllSolutions(tokens[:pr.split], separated, start)
+		p2 := findAllSolutions(tokens[pr.split:], separated, start+pr.split)
+		if checkpointer.stopped() {
+			return nil
+		}
+		for ; pr.pairs < len(p1)*len(p2); pr.pairs++ {
+			if checkpointer.stopped() {
+				return nil
 			}
+			checkpointer.progress()
+			s1, s2 := p1[pr.pairs/len(p2)], p2[pr.pairs%len(p2)]
+			pr.results = append(pr.results, s1.AllBinary(s2)...)
 		}
 	}
-	return uniq(r)
+	r := uniq(pr.results)
+	delete(inProgress, rng)
+	completed[rng] = r
+	checkpointer.done(rng)
+	return r
 }
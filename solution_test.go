@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolutionUnaryFixedPoint(t *testing.T) {
+	assert := assert.New(t)
+	s := Solution{val: rational{2, 1}, start: 0, end: 1}
+	assert.Equal(NoSolution, s.Unary(OpFact), "2! = 2 adds nothing new")
+	// Used to loop forever applying the factorial to 2
+	assert.Len(s.AllUnary(), 2) // 2 and -2
+}
+
+func TestSearchStats(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 0
+	p, st := Search("12")
+	assert.NotEmpty(p)
+	assert.Equal(0, st.Duplicates, "only one formula is kept for each value")
+	assert.Equal(st.Added, st.Peak)
+	var out bytes.Buffer
+	st.Print(&out)
+	assert.Contains(out.String(), fmt.Sprintf("(peak %d)", st.Peak))
+
+	// Formulas for 1 are kept from the previous search, like in the batch mode
+	keepPrefix(1)
+	_, st = search("13")
+	assert.Equal(stored, st.Peak)
+	assert.True(st.Added < st.Peak)
+	assert.True(st.Tried[OpAdd] > 0)
+	assert.Equal(st.Tried[OpAdd], st.Found[OpAdd])
+	assert.True(st.Tried[OpDiv] > 0)
+	assert.True(st.Rejected[ErrNotRational.Error()] > 0, "sqrt(2) and alike should be rejected")
+	assert.True(st.Ranges[Range{0, 2}] > 0)
+	assert.Equal(0, st.Ranges[Range{1, 1}])
+
+	maxDepth = 2
+	_, st = Search("12")
+	assert.True(st.Duplicates > 0)
+	maxDepth = 0
+}
+
+func TestSearchDecimals(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 0
+	decimals = true
+	defer func() { decimals = false }()
+	p, _ := Search("1")
+	formulas := p.Formulas(rat("1/9"))
+	if assert.Len(formulas, 1) {
+		assert.Equal(".(1)", formulas[0].String())
+	}
+	p, _ = Search("44")
+	formulas = p.Formulas(rat("10"))
+	if assert.NotEmpty(formulas) {
+		v, err := formulas[0].Eval()
+		assert.NoError(err)
+		assert.Equal(rat("10"), v)
+	}
+}
+
+func TestEnableOps(t *testing.T) {
+	assert := assert.New(t)
+	defer func(b, u []Op) { binaryOps, extraUnaryOps = b, u }(binaryOps, extraUnaryOps)
+	maxDepth = 0
+	p, _ := Search("4")
+	assert.Empty(p.Formulas(rat("9")))
+	key := opsKey()
+
+	assert.NoError(enableOps("!!,subfact,#,!"))
+	assert.Equal([]Op{OpDoubleFact, OpSubfact, OpPrimorial}, extraUnaryOps)
+	assert.NotEqual(key, opsKey())
+	p, _ = Search("4")
+	if formulas := p.Formulas(rat("9")); assert.Len(formulas, 1) {
+		assert.Equal("!4", formulas[0].String())
+	}
+	assert.NotEmpty(p.Formulas(rat("8")))
+	assert.NotEmpty(p.Formulas(rat("6")))
+
+	assert.Error(enableOps("!!,foo"))
+}
+
+func TestSearchRounding(t *testing.T) {
+	assert := assert.New(t)
+	defer func(u []Op) { extraUnaryOps = u }(extraUnaryOps)
+	assert.NoError(enableOps("floor,ceil,round"))
+	maxDepth = 3
+	p, _ := Search("72")
+	found := make(map[string]bool)
+	for _, v := range []string{"3", "4"} {
+		for _, n := range p.Formulas(rat(v)) {
+			found[n.String()] = true
+		}
+	}
+	assert.True(found["⌊7 / 2⌋"])
+	assert.True(found["⌈7 / 2⌉"])
+	assert.True(found["round(7 / 2)"])
+	assert.False(found["⌊⌊7 / 2⌋⌋"])
+
+	n, _ := FromPolish("floor ceil / 7 2")
+	assert.Equal("⌈7 / 2⌉", n.Simplify().String())
+}
+
+func TestSearchRecipAbs(t *testing.T) {
+	assert := assert.New(t)
+	defer func(u []Op) { extraUnaryOps = u }(extraUnaryOps)
+	assert.NoError(enableOps("recip,abs"))
+	maxDepth = 0
+	p, _ := Search("4")
+	if formulas := p.Formulas(rat("1/4")); assert.Len(formulas, 1) {
+		assert.Equal("1/(4)", formulas[0].String())
+	}
+
+	for polish, simple := range map[string]string{
+		"recip recip 4": "4",
+		"abs -- - 1 5":  "|1 - 5|",
+		"abs abs -- 4":  "|4|",
+	} {
+		n, _ := FromPolish(polish)
+		assert.Equal(simple, n.Simplify().String(), polish)
+	}
+}
+
+func TestSearchIntDiv(t *testing.T) {
+	assert := assert.New(t)
+	defer func(b []Op) { binaryOps = b }(binaryOps)
+	assert.NoError(enableOps("div,mod"))
+	maxDepth = 0
+	p, _ := Search("73")
+	assert.NotEmpty(p.Formulas(rat("2")))
+	if formulas := p.Formulas(rat("1")); assert.NotEmpty(formulas) {
+		v, err := formulas[0].Eval()
+		assert.NoError(err)
+		assert.Equal(rat("1"), v)
+	}
+
+	// Rules for / don't hold for div
+	for _, polish := range []string{"div -- 7 2", "div 8 div 4 2", "div div 9 2 3"} {
+		n, _ := FromPolish(polish)
+		assert.Equal(polish, n.Simplify().ToPolish())
+	}
+}
+
+func TestSearchCombinatorial(t *testing.T) {
+	assert := assert.New(t)
+	defer func(b []Op) { binaryOps = b }(binaryOps)
+	assert.NoError(enableOps("C,P"))
+	maxDepth = 3
+	p, _ := Search("52")
+	found := make(map[string]bool)
+	for _, v := range []string{"10", "20"} {
+		for _, n := range p.Formulas(rat(v)) {
+			found[n.String()] = true
+		}
+	}
+	assert.True(found["C(5, 2)"])
+	assert.True(found["P(5, 2)"])
+}
+
+func TestSearchRoot(t *testing.T) {
+	assert := assert.New(t)
+	defer func(b []Op) { binaryOps = b }(binaryOps)
+	maxDepth = 0
+	p, _ := Search("327")
+	assert.Empty(p.Formulas(rat("343")))
+
+	assert.NoError(enableOps("root"))
+	p, _ = Search("327")
+	if formulas := p.Formulas(rat("343")); assert.Len(formulas, 1) {
+		assert.Equal("root(sqrt(3 ^ -2), 7)", formulas[0].String())
+	}
+}
+
+func TestSearchLog(t *testing.T) {
+	assert := assert.New(t)
+	defer func(b []Op) { binaryOps = b }(binaryOps)
+	assert.NoError(enableOps("log"))
+	maxDepth = 3
+	p, _ := Search("28")
+	found := make(map[string]bool)
+	for _, n := range p.Formulas(rat("3")) {
+		found[n.String()] = true
+	}
+	assert.True(found["log_2(8)"])
+}
+
+func TestSearchBase(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 0
+	withBase(16, func() {
+		p, _ := Search("abc")
+		formulas := p.Formulas(rat("fc"))
+		if assert.Len(formulas, 1) {
+			assert.Equal("(a + b) * c", formulas[0].String())
+		}
+		assert.True(inRange(rational{252, 1}, atoiBase("F0"), atoiBase("ff")))
+		assert.Equal("fc", rational{252, 1}.String())
+	})
+}
+
+func TestSearchTokens(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []struct {
+		digits    string
+		tokens    []string
+		separated bool
+	}{
+		{"123", []string{"1", "2", "3"}, false},
+		{"25 50 7", []string{"25", "50", "7"}, true},
+		{"25,50,7", []string{"25", "50", "7"}, true},
+		{" 25, 50  7 ", []string{"25", "50", "7"}, true},
+	} {
+		tokens, separated := splitTokens(tc.digits)
+		assert.Equal(tc.tokens, tokens, tc.digits)
+		assert.Equal(tc.separated, separated, tc.digits)
+	}
+
+	maxDepth = 0
+	p, _ := Search("25 50 7")
+	assert.Empty(p.Formulas(rat("25507")))
+	formulas := p.Formulas(rat("82"))
+	if assert.Len(formulas, 1) {
+		assert.Equal("25 + 50 + 7", formulas[0].String())
+	}
+	for _, s := range p {
+		assert.True(s.start == 0 && s.end == 3)
+	}
+	assert.NotEmpty(p.Formulas(rat("2")))
+}
+
+// assertStoredFormulas checks that every formula in the solutions table evaluates to
+// the value it's stored under. Bounds of intervals depend on the order of operations,
+// which Simplify may change, so they only have to overlap.
+func assertStoredFormulas(t *testing.T) {
+	assert := assert.New(t)
+	for s, nodes := range solutions {
+		for _, n := range nodes {
+			v, err := n.Eval()
+			if !assert.NoError(err, "%s", n) {
+				continue
+			} else if iv, ok := v.(interval); ok {
+				sv := s.val.(interval)
+				assert.True(iv.lo <= sv.hi && sv.lo <= iv.hi, "%s = %s is stored under %s", n, v, s.val)
+			} else {
+				assert.True(v.Equal(s.val), "%s = %s is stored under %s", n, v, s.val)
+			}
+		}
+	}
+}
+
+func TestStoredFormulas(t *testing.T) {
+	defer func() { maxDepth = 0 }()
+	for _, tc := range []struct {
+		name   string
+		mode   func(on bool)
+		digits []string
+	}{
+		{"rational", func(bool) {}, []string{"44", "1234", "2348"}},
+		{"surds", func(on bool) { surds = on }, []string{"44", "1234"}},
+		{"complex", func(on bool) { complexValues = on }, []string{"44", "1234", "2348"}},
+		{"intervals", func(on bool) {
+			intervals, maxUnaryChain = on, 6
+			if on {
+				maxUnaryChain = approxUnaryChain
+			}
+		}, []string{"44", "123"}},
+		{"residues", func(on bool) {
+			modulus = 0
+			if on {
+				modulus = 7
+			}
+		}, []string{"44", "1234"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mode(true)
+			defer tc.mode(false)
+			for _, digits := range tc.digits {
+				maxDepth = 3
+				Search(digits)
+				assertStoredFormulas(t)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Range is a range of the source tokens (see splitTokens), tokens[Start:End].
+type Range struct {
+	Start, End int
+}
+
+// Stats collects statistics of a search, which are useful for tuning depth limits.
+type Stats struct {
+	Tried      map[Op]int     // operations tried, per operator
+	Found      map[Op]int     // operations which produced a value, per operator
+	Rejected   map[string]int // operations which failed, per error kind
+	Ranges     map[Range]int  // operations tried, per range of digits used by the result
+	Added      int            // formulas stored by Solution.Add
+	Duplicates int            // formulas dropped by Solution.Add as duplicates
+	TooDeep    int            // formulas dropped by Solution.Add as too deep
+	Peak       int            // peak number of formulas in the solutions table, including reused ones
+	Elapsed    time.Duration
+}
+
+// stats is updated by the search functions.
+var stats = newStats()
+
+// newStats returns an empty Stats.
+func newStats() *Stats {
+	return &Stats{
+		Tried:    make(map[Op]int),
+		Found:    make(map[Op]int),
+		Rejected: make(map[string]int),
+		Ranges:   make(map[Range]int),
+	}
+}
+
+// errorKinds lists error kinds reported in Stats.Rejected.
+var errorKinds = []error{ErrDivByZero, ErrOverflow, ErrNotRational, ErrDomain, ErrInvalidNode, ErrNotSurd, ErrMismatch}
+
+// errKind returns a short name of the kind of err, as used in Stats.Rejected.
+func errKind(err error) string {
+	for _, e := range errorKinds {
+		if errors.Is(err, e) {
+			return e.Error()
+		}
+	}
+	return "other"
+}
+
+// tried records an attempt to apply op to produce a value for digits[start:end]
+// with the result err.
+func (st *Stats) tried(op Op, start, end int, err error) {
+	st.Tried[op]++
+	st.Ranges[Range{start, end}]++
+	if err != nil {
+		st.Rejected[errKind(err)]++
+	} else {
+		st.Found[op]++
+	}
+}
+
+// Print writes st in a human-readable form to w.
+func (st *Stats) Print(w io.Writer) {
+	fmt.Fprintf(w, "Elapsed: %s\n", st.Elapsed)
+	fmt.Fprintf(w, "Formulas: %d stored (peak %d), %d duplicates, %d too deep\n",
+		st.Added, st.Peak, st.Duplicates, st.TooDeep)
+	fmt.Fprintf(w, "Operators:\n")
+	var ops []Op
+	for op := range st.Tried {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	for _, op := range ops {
+		fmt.Fprintf(w, "  %-6s tried %10d  found %10d\n", op, st.Tried[op], st.Found[op])
+	}
+	fmt.Fprintf(w, "Rejected:\n")
+	var kinds []string
+	for k := range st.Rejected {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		fmt.Fprintf(w, "  %-24s %10d\n", k, st.Rejected[k])
+	}
+	fmt.Fprintf(w, "Ranges:\n")
+	var ranges []Range
+	for r := range st.Ranges {
+		ranges = append(ranges, r)
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].Start != ranges[j].Start {
+			return ranges[i].Start < ranges[j].Start
+		}
+		return ranges[i].End < ranges[j].End
+	})
+	for _, r := range ranges {
+		fmt.Fprintf(w, "  [%d:%d] tried %10d\n", r.Start, r.End, st.Ranges[r])
+	}
+}
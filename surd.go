@@ -0,0 +1,373 @@
+// This file contains quadratic surds, exact values like 1 + 2*sqrt(3) used with --surds.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// surd stores a + b*sqrt(c) with rational a and b, and squarefree integer c > 1. Rational
+// values are stored with b == 0 and c == 1, so that equal values are equal structs.
+type surd struct {
+	a, b rational
+	c    int64
+}
+
+// maxRadicand limits c, so that squares are quickly factored out of it.
+const maxRadicand = 1 << 20
+
+// newSurd creates a normalized surd a + b*sqrt(c) for a squarefree c.
+func newSurd(a, b rational, c int64) surd {
+	if b.Zero() || c == 1 {
+		return surd{a: a.normalize(), b: rational{0, 1}, c: 1}
+	}
+	return surd{a: a.normalize(), b: b.normalize(), c: c}
+}
+
+// ratSurd converts r to surd.
+func ratSurd(r rational) surd {
+	return newSurd(r, rational{0, 1}, 1)
+}
+
+// toSurd converts surds and rationals to surd.
+func toSurd(v Value) (surd, bool) {
+	switch v := v.(type) {
+	case surd:
+		return v, true
+	case rational:
+		return ratSurd(v), true
+	}
+	return surd{}, false
+}
+
+// rational returns s as rational, and false if s is irrational.
+func (s surd) rational() (rational, bool) {
+	return s.a, s.b.Zero()
+}
+
+// String returns s in the infix notation, which is parsed back by parseValue.
+func (s surd) String() string {
+	if s.b.Zero() {
+		return s.a.String()
+	}
+	b, sign := s.b, " + "
+	if b.Negative() {
+		b, sign = b.Minus(), " - "
+	}
+	root := fmt.Sprintf("sqrt(%s)", formatInt(s.c))
+	if !b.One() {
+		root = b.String() + " * " + root
+	}
+	if s.a.Zero() && sign == " - " {
+		return "-" + root
+	} else if s.a.Zero() {
+		return root
+	}
+	return s.a.String() + sign + root
+}
+
+// ratCalc performs rational operations on parts of surds, remembering the first error.
+type ratCalc struct {
+	err error
+}
+
+func (c *ratCalc) do(f func(rational) (rational, error), a rational) rational {
+	if c.err != nil {
+		return rational{0, 1}
+	}
+	r, err := f(a)
+	c.err = err
+	return r
+}
+
+func (c *ratCalc) add(a, b rational) rational {
+	return c.do(a.Add, b)
+}
+
+func (c *ratCalc) mul(a, b rational) rational {
+	return c.do(a.Mul, b)
+}
+
+// rewrap returns err of an operation on parts of surds as an error of op applied to args.
+func rewrap(err error, op Op, args ...Value) error {
+	var e *OpError
+	if errors.As(err, &e) {
+		return newOpError(e.Err, op, args...)
+	}
+	return err
+}
+
+// PerformUnary is an implementation of Value.PerformUnary. Operators other than minus,
+// sqrt, reciprocal and absolute value are only defined for rational s.
+func (s surd) PerformUnary(op Op) (Value, error) {
+	switch op {
+	case OpMinus:
+		return s.minus(), nil
+	case OpSqrt:
+		return s.sqrt()
+	case OpRecip:
+		r, err := ratSurd(rational{1, 1}).div(s)
+		return r, rewrap(err, op, s)
+	case OpAbs:
+		if s.Negative() {
+			return s.minus(), nil
+		}
+		return s, nil
+	}
+	r, ok := s.rational()
+	if !ok {
+		return surd{}, newOpError(ErrDomain, op, s)
+	}
+	v, err := r.PerformUnary(op)
+	if err != nil {
+		return surd{}, rewrap(err, op, s)
+	}
+	return ratSurd(v.(rational)), nil
+}
+
+// PerformBinary is an implementation of Value.PerformBinary. Operators other than
+// arithmetic and roots are only defined for rational arguments.
+func (s surd) PerformBinary(op Op, v Value) (Value, error) {
+	t, ok := toSurd(v)
+	if !ok {
+		return surd{}, newOpError(ErrMismatch, op, s, v)
+	}
+	var r surd
+	var err error
+	switch op {
+	case OpAdd:
+		r, err = s.add(t)
+	case OpSub:
+		r, err = s.add(t.minus())
+	case OpMul:
+		r, err = s.mul(t)
+	case OpDiv:
+		r, err = s.div(t)
+	case OpPow:
+		r, err = s.pow(t)
+	case OpRoot:
+		if s.Zero() {
+			return surd{}, newOpError(ErrDomain, op, s, t)
+		}
+		var e surd
+		if e, err = ratSurd(rational{1, 1}).div(s); err == nil {
+			r, err = t.pow(e)
+		}
+	default:
+		r1, ok1 := s.rational()
+		r2, ok2 := t.rational()
+		if !ok1 || !ok2 {
+			return surd{}, newOpError(ErrDomain, op, s, t)
+		}
+		var v Value
+		if v, err = r1.PerformBinary(op, r2); err == nil {
+			r = ratSurd(v.(rational))
+		}
+	}
+	if err != nil {
+		return surd{}, rewrap(err, op, s, t)
+	}
+	return r, nil
+}
+
+func (s surd) minus() surd {
+	return newSurd(s.a.Minus(), s.b.Minus(), s.c)
+}
+
+// conj returns a - b*sqrt(c).
+func (s surd) conj() surd {
+	return newSurd(s.a, s.b.Minus(), s.c)
+}
+
+func (s surd) add(t surd) (surd, error) {
+	c := s.c
+	if s.b.Zero() {
+		c = t.c
+	} else if !t.b.Zero() && t.c != c {
+		return surd{}, newOpError(ErrNotSurd, OpAdd, s, t)
+	}
+	var calc ratCalc
+	a, b := calc.add(s.a, t.a), calc.add(s.b, t.b)
+	return newSurd(a, b, c), calc.err
+}
+
+// mul returns s * t if both have the same radicand, or if they are b1*sqrt(c1) and
+// b2*sqrt(c2), which is b1*b2*g*sqrt(c1*c2/g^2) for g = gcd(c1, c2).
+func (s surd) mul(t surd) (surd, error) {
+	var calc ratCalc
+	if s.b.Zero() || t.b.Zero() || s.c == t.c {
+		c := s.c
+		if c == 1 {
+			c = t.c
+		}
+		bbc := calc.mul(calc.mul(s.b, t.b), rational{c, 1})
+		a := calc.add(calc.mul(s.a, t.a), bbc)
+		b := calc.add(calc.mul(s.a, t.b), calc.mul(s.b, t.a))
+		return newSurd(a, b, c), calc.err
+	} else if !s.a.Zero() || !t.a.Zero() {
+		return surd{}, newOpError(ErrNotSurd, OpMul, s, t)
+	}
+	g := gcd(s.c, t.c)
+	c := (s.c / g) * (t.c / g)
+	if c > maxRadicand {
+		return surd{}, newOpError(ErrOverflow, OpMul, s, t)
+	}
+	b := calc.mul(calc.mul(s.b, t.b), rational{g, 1})
+	return newSurd(rational{0, 1}, b, c), calc.err
+}
+
+// div returns s * conj(t) / (t * conj(t)), where the denominator is rational. Rational
+// t divides s directly, as t * conj(t) = t^2 may overflow.
+func (s surd) div(t surd) (surd, error) {
+	if t.Zero() {
+		return surd{}, newOpError(ErrDivByZero, OpDiv, s, t)
+	} else if t.b.Zero() {
+		var calc ratCalc
+		a, b := calc.do(s.a.Div, t.a), calc.do(s.b.Div, t.a)
+		return newSurd(a, b, s.c), calc.err
+	}
+	num, err := s.mul(t.conj())
+	if err != nil {
+		return surd{}, err
+	}
+	den, err := t.mul(t.conj())
+	if err != nil {
+		return surd{}, err
+	}
+	var calc ratCalc
+	a, b := calc.do(num.a.Div, den.a), calc.do(num.b.Div, den.a)
+	return newSurd(a, b, num.c), calc.err
+}
+
+// maxSurdPower limits integer powers of irrational surds, which overflow soon anyway.
+const maxSurdPower = 64
+
+// pow returns s^t for rational t. Irrational s can only be raised to integer powers,
+// and rational s also to powers with denominator 2.
+func (s surd) pow(t surd) (surd, error) {
+	e, ok := t.rational()
+	if !ok {
+		return surd{}, newOpError(ErrNotSurd, OpPow, s, t)
+	}
+	if r, ok := s.rational(); ok {
+		p, err := r.Pow(e)
+		if err == nil {
+			return ratSurd(p), nil
+		} else if !errors.Is(err, ErrNotRational) || e.d != 2 || r.Negative() {
+			return surd{}, err
+		}
+		sq, err := s.sqrt()
+		if err != nil {
+			return surd{}, err
+		}
+		return sq.pow(ratSurd(rational{e.n, 1}))
+	}
+	if e.d != 1 {
+		return surd{}, newOpError(ErrNotSurd, OpPow, s, t)
+	} else if e.n < 0 {
+		inv, err := ratSurd(rational{1, 1}).div(s)
+		if err != nil {
+			return surd{}, err
+		}
+		return inv.pow(ratSurd(e.Minus()))
+	} else if e.n > maxSurdPower {
+		return surd{}, newOpError(ErrOverflow, OpPow, s, t)
+	}
+	p := ratSurd(rational{1, 1})
+	for i := int64(0); i < e.n; i++ {
+		var err error
+		if p, err = p.mul(s); err != nil {
+			return surd{}, err
+		}
+	}
+	return p, nil
+}
+
+// sqrt returns the square root of rational s: for a/b it is sqrt(a*b) / b, with squares
+// factored out of a*b.
+func (s surd) sqrt() (surd, error) {
+	r, ok := s.rational()
+	if !ok {
+		return surd{}, newOpError(ErrNotSurd, OpSqrt, s)
+	}
+	q, err := r.Sqrt()
+	if err == nil {
+		return ratSurd(q), nil
+	} else if !errors.Is(err, ErrNotRational) {
+		return surd{}, rewrap(err, OpSqrt, s)
+	}
+	m, ok := mul64(r.n, r.d)
+	if !ok || m > maxRadicand {
+		return surd{}, newOpError(ErrOverflow, OpSqrt, s)
+	}
+	k, c := squarefree(m)
+	return newSurd(rational{0, 1}, rational{k, r.d}, c), nil
+}
+
+// Equal is an implementation of Value.Equal
+func (s surd) Equal(v Value) bool {
+	t, ok := toSurd(v)
+	return ok && s == t
+}
+
+// Less is an implementation of Value.Less. Irrational values are compared approximately.
+func (s surd) Less(v Value) bool {
+	t, ok := toSurd(v)
+	if !ok {
+		return false
+	}
+	r1, ok1 := s.rational()
+	r2, ok2 := t.rational()
+	if ok1 && ok2 {
+		return r1.isLess(r2)
+	}
+	return s.Value() < t.Value()
+}
+
+func (s surd) Value() float64 {
+	return s.a.Value() + s.b.Value()*math.Sqrt(float64(s.c))
+}
+
+func (s surd) IsInteger() bool {
+	r, ok := s.rational()
+	return ok && r.IsInteger()
+}
+
+// Negative returns true for s < 0. Unless a and b have the same sign, it compares a^2
+// with b^2*c, or the approximate values if that overflows.
+func (s surd) Negative() bool {
+	if s.b.Zero() {
+		return s.a.Negative()
+	} else if s.a.Zero() || s.a.Negative() == s.b.Negative() {
+		return s.b.Negative()
+	}
+	var calc ratCalc
+	a2 := calc.mul(s.a, s.a)
+	b2c := calc.mul(calc.mul(s.b, s.b), rational{s.c, 1})
+	if calc.err != nil {
+		return s.Value() < 0
+	} else if b2c.isLess(a2) {
+		return s.a.Negative()
+	}
+	return s.b.Negative()
+}
+
+func (s surd) Even() bool {
+	r, ok := s.rational()
+	return ok && r.Even()
+}
+
+func (s surd) Zero() bool {
+	return s.a.Zero() && s.b.Zero()
+}
+
+func (s surd) One() bool {
+	r, ok := s.rational()
+	return ok && r.One()
+}
+
+func (s surd) MinusOne() bool {
+	r, ok := s.rational()
+	return ok && r.MinusOne()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sur parses a surd written in the infix notation, like Value.String returns it.
+func sur(s string) Value {
+	defer func(b bool) { surds = b }(surds)
+	surds = true
+	v, err := parseValue(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestSurdOps(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []testCase{
+		{"sqrt(2)", OpMul, "sqrt(8)", "4"},
+		{"sqrt(2)", OpAdd, "sqrt(8)", "3 * sqrt(2)"},
+		{"1 + sqrt(5)", OpDiv, "2", "1/2 + 1/2 * sqrt(5)"},
+		{"1 + sqrt(2)", OpMul, "1 - sqrt(2)", "-1"},
+		{"1", OpDiv, "1 + sqrt(2)", "-1 + sqrt(2)"},
+		{"sqrt(6)", OpMul, "sqrt(10)", "2 * sqrt(15)"},
+		{"sqrt(6)", OpDiv, "sqrt(3)", "sqrt(2)"},
+		{"1 + sqrt(2)", OpPow, "2", "3 + 2 * sqrt(2)"},
+		{"sqrt(2)", OpPow, "-3", "1/4 * sqrt(2)"},
+		{"8", OpPow, "1/2", "2 * sqrt(2)"},
+		{"2", OpPow, "-3/2", "1/4 * sqrt(2)"},
+		{"2", OpRoot, "12", "2 * sqrt(3)"},
+		{"sqrt(3)", OpSub, "sqrt(3)", "0"},
+	} {
+		v, err := sur(tc.a).PerformBinary(tc.op, sur(tc.b))
+		assert.NoError(err)
+		assert.Equal(sur(tc.r), v, "%s %s %s", tc.a, tc.op, tc.b)
+	}
+	for _, tc := range []struct{ a, r string }{
+		{"2", "sqrt(2)"},
+		{"1/2", "1/2 * sqrt(2)"},
+		{"9/4", "3/2"},
+		{"72", "6 * sqrt(2)"},
+	} {
+		v, err := sur(tc.a).PerformUnary(OpSqrt)
+		assert.NoError(err)
+		assert.Equal(sur(tc.r), v, "sqrt(%s)", tc.a)
+	}
+	v, err := sur("1 - sqrt(3)").PerformUnary(OpAbs)
+	assert.NoError(err)
+	assert.Equal(sur("-1 + sqrt(3)"), v)
+
+	for _, tc := range []struct {
+		a  string
+		op Op
+		b  string
+		e  error
+	}{
+		{"sqrt(2)", OpAdd, "sqrt(3)", ErrNotSurd},
+		{"1 + sqrt(2)", OpMul, "sqrt(3)", ErrNotSurd},
+		{"2", OpPow, "sqrt(2)", ErrNotSurd},
+		{"sqrt(2)", OpPow, "1/2", ErrNotSurd},
+		{"2", OpPow, "1/3", ErrNotRational},
+		{"sqrt(2)", OpDiv, "0", ErrDivByZero},
+		{"sqrt(2)", OpIntDiv, "2", ErrDomain},
+	} {
+		_, err := sur(tc.a).PerformBinary(tc.op, sur(tc.b))
+		assert.True(errors.Is(err, tc.e), "%s %s %s: %v", tc.a, tc.op, tc.b, err)
+	}
+	_, err = sur("sqrt(2)").PerformUnary(OpFact)
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = sur("-2").PerformUnary(OpSqrt)
+	assert.True(errors.Is(err, ErrDomain))
+	_, err = sur("1 + sqrt(2)").PerformUnary(OpSqrt)
+	assert.True(errors.Is(err, ErrNotSurd))
+	var e *OpError
+	_, err = sur("sqrt(2)").PerformBinary(OpAdd, sur("sqrt(3)"))
+	assert.True(errors.As(err, &e))
+	assert.Equal([]Value{sur("sqrt(2)"), sur("sqrt(3)")}, e.Args)
+}
+
+func TestSurdMisc(t *testing.T) {
+	assert := assert.New(t)
+	for _, s := range []string{"3", "-3/4", "sqrt(2)", "-sqrt(2)", "1/2 * sqrt(3)", "1 - 2 * sqrt(3)"} {
+		assert.Equal(s, sur(s).String())
+	}
+	assert.True(sur("1 - sqrt(2)").Negative())
+	assert.False(sur("-1 + sqrt(2)").Negative())
+	assert.True(sur("-sqrt(2)").Negative())
+	assert.True(sur("sqrt(2)").Less(sur("3/2")))
+	assert.True(sur("4").Equal(rat("4")))
+	assert.True(rat("4").Equal(sur("4")))
+	assert.False(rat("2").Equal(sur("sqrt(2)")))
+	assert.True(sur("4").IsInteger())
+	assert.False(sur("2 * sqrt(2)").IsInteger())
+	_, ok := toRational(sur("sqrt(2)"))
+	assert.False(ok)
+	assert.False(inRange(sur("sqrt(2)"), 1, 0))
+	assert.True(inRange(sur("4"), 1, 10))
+}
+
+func TestSearchSurds(t *testing.T) {
+	assert := assert.New(t)
+	maxDepth = 3
+	surds = true
+	defer func() { surds = false }()
+	p, _ := Search("282")
+	found := make(map[string]bool)
+	for _, n := range p.Formulas(rat("18")) {
+		found[n.String()] = true
+		v, err := n.Eval()
+		assert.NoError(err)
+		assert.True(v.Equal(rat("18")))
+	}
+	assert.True(found["(sqrt(2) + sqrt(8)) ^ 2"])
+	assertStoredFormulas(t)
+
+	// Merging square roots must not make formulas overflow
+	n, err := FromPolish("* sqrt ^ sqrt * 2 3 ! 4 sqrt ! 8")
+	assert.NoError(err)
+	v, err := n.Simplify().Eval()
+	assert.NoError(err)
+	assert.True(v.Equal(sur("1119744 * sqrt(70)")), "%s", v)
+	v, err = sur("sqrt(2)").PerformBinary(OpDiv, sur("268738560000"))
+	assert.NoError(err)
+	assert.Equal(sur("1/268738560000 * sqrt(2)"), v)
+
+	s := Solution{val: sur("1 - 2 * sqrt(3)"), start: 0, end: 2}
+	s1, err := newCacheEntry(s).solution()
+	assert.NoError(err)
+	assert.Equal(s, s1)
+}